@@ -0,0 +1,261 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/grafana/configparser"
+)
+
+// typeComment is the prefix of a comment setting the type of the option below it, e.g. "# type: duration"
+const typeComment = "# type:"
+
+// parsers maps the supported field types to the Go type, the expression parsing a string value v into
+// that type and the package it needs. Strings need no parsing.
+var parsers = map[string]struct {
+	goType, expr, pkg string
+}{
+	"string":   {"string", "", ""},
+	"int":      {"int", "strconv.Atoi(v)", "strconv"},
+	"float":    {"float64", "strconv.ParseFloat(v, 64)", "strconv"},
+	"bool":     {"bool", "strconv.ParseBool(v)", "strconv"},
+	"duration": {"time.Duration", "time.ParseDuration(v)", "time"},
+}
+
+type field struct {
+	name, option, typ, def string
+}
+
+type structDef struct {
+	name, section string
+	fields        []field
+}
+
+// generate returns the formatted Go source for the typed configuration described by the example conf
+func generate(conf *configparser.Configuration, pkg string) ([]byte, error) {
+	global, sections, err := conf.AllSections()
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := newStruct("Config", global)
+	if err != nil {
+		return nil, err
+	}
+	var defs []structDef
+	seen := make(map[string]bool)
+	for _, s := range sections {
+		if seen[s.Name()] {
+			continue
+		}
+		seen[s.Name()] = true
+		def, err := newStruct(identifier(s.Name()), s)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	uniquify(root.fields, defs)
+
+	imports := map[string]bool{"github.com/grafana/configparser": true}
+	for _, def := range append([]structDef{root}, defs...) {
+		for _, f := range def.fields {
+			if p := parsers[f.typ].pkg; p != "" {
+				imports["fmt"] = true
+				imports[p] = true
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by configgen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	var paths []string
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(&b, "\t%q\n", path)
+	}
+	b.WriteString(")\n\n")
+
+	b.WriteString("// Config is the typed configuration.\ntype Config struct {\n")
+	writeFields(&b, root.fields)
+	for _, def := range defs {
+		fmt.Fprintf(&b, "\t%s %s\n", def.name, def.name)
+	}
+	b.WriteString("}\n\n")
+	for _, def := range defs {
+		fmt.Fprintf(&b, "// %s holds the options of section [%s].\ntype %s struct {\n", def.name, def.section, def.name)
+		writeFields(&b, def.fields)
+		b.WriteString("}\n\n")
+	}
+
+	// conf and s are only declared if there are options to load, as unused variables don't compile
+	empty := true
+	for _, def := range append([]structDef{root}, defs...) {
+		empty = empty && len(def.fields) == 0
+	}
+	b.WriteString(`// Load reads the configuration file at path into a Config.
+// Missing sections and options get the values of the example configuration the code was generated from.
+func Load(path string) (*Config, error) {
+`)
+	if empty {
+		b.WriteString("\tif _, err := configparser.ReadFile(path); err != nil {\n\t\treturn nil, err\n\t}\n\tvar c Config\n")
+	} else {
+		b.WriteString("\tconf, err := configparser.ReadFile(path)\n\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+		b.WriteString("\tvar c Config\n\tvar s *configparser.Section\n")
+	}
+	if len(root.fields) > 0 {
+		b.WriteString("\ts = conf.GlobalSection()\n")
+		writeLoads(&b, "c", "", root.fields)
+	}
+	for _, def := range defs {
+		if len(def.fields) > 0 {
+			fmt.Fprintf(&b, "\ts, _ = conf.Section(%q)\n", def.section)
+			writeLoads(&b, "c."+def.name, def.section+".", def.fields)
+		}
+	}
+	b.WriteString(`	return &c, nil
+}
+
+// value returns the value of option in s, or def if s or the option doesn't exist
+func value(s *configparser.Section, option, def string) string {
+	if s == nil || !s.Exists(option) {
+		return def
+	}
+	return s.ValueOfWithoutComments(option)
+}
+`)
+
+	return format.Source(b.Bytes())
+}
+
+// newStruct returns the definition of the struct named name for the options of section s
+func newStruct(name string, s *configparser.Section) (structDef, error) {
+	def := structDef{name: name, section: s.Name()}
+	typ := ""
+	for _, opt := range s.OptionNames() {
+		if strings.HasPrefix(opt, typeComment) {
+			typ = strings.TrimSpace(strings.TrimPrefix(opt, typeComment))
+			if _, ok := parsers[typ]; !ok {
+				return def, fmt.Errorf("section %q: unsupported type %q", s.Name(), typ)
+			}
+			continue
+		}
+		if opt == "" || strings.HasPrefix(opt, "#") {
+			continue
+		}
+		value := s.ValueOfWithoutComments(opt)
+		if typ == "" {
+			typ = inferType(value)
+		}
+		def.fields = append(def.fields, field{name: identifier(opt), option: opt, typ: typ, def: value})
+		typ = ""
+	}
+	return def, nil
+}
+
+// inferType returns the most specific type the example value parses as
+func inferType(value string) string {
+	if _, err := strconv.Atoi(value); err == nil {
+		return "int"
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return "float"
+	}
+	if _, err := strconv.ParseBool(value); err == nil {
+		return "bool"
+	}
+	if _, err := time.ParseDuration(value); err == nil {
+		return "duration"
+	}
+	return "string"
+}
+
+// identifier turns a section or option name into an exported Go identifier, e.g. "max-open_files" -> "MaxOpenFiles"
+func identifier(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	id := b.String()
+	if id == "" || !unicode.IsLetter([]rune(id)[0]) {
+		id = "X" + id
+	}
+	return id
+}
+
+// uniquify renames fields and structs whose identifiers collide, e.g. for options "a-b" and "a_b"
+func uniquify(global []field, defs []structDef) {
+	dedupe := func(names []*string, used map[string]bool) {
+		for _, name := range names {
+			base := *name
+			for i := 2; used[*name]; i++ {
+				*name = base + strconv.Itoa(i)
+			}
+			used[*name] = true
+		}
+	}
+
+	// global fields share the Config struct with the section structs
+	var names []*string
+	for i := range global {
+		names = append(names, &global[i].name)
+	}
+	for i := range defs {
+		names = append(names, &defs[i].name)
+	}
+	dedupe(names, map[string]bool{"Config": true})
+
+	for _, def := range defs {
+		names = names[:0]
+		for i := range def.fields {
+			names = append(names, &def.fields[i].name)
+		}
+		dedupe(names, make(map[string]bool))
+	}
+}
+
+func writeFields(b *bytes.Buffer, fields []field) {
+	for _, f := range fields {
+		fmt.Fprintf(b, "\t%s %s\n", f.name, parsers[f.typ].goType)
+	}
+}
+
+func writeLoads(b *bytes.Buffer, target, prefix string, fields []field) {
+	for _, f := range fields {
+		if f.typ == "string" {
+			fmt.Fprintf(b, "\t%s.%s = value(s, %q, %q)\n", target, f.name, f.option, f.def)
+			continue
+		}
+		fmt.Fprintf(b, `	{
+		v := value(s, %q, %q)
+		parsed, err := %s
+		if err != nil {
+			return nil, fmt.Errorf(%q, err)
+		}
+		%s.%s = parsed
+	}
+`, f.option, f.def, parsers[f.typ].expr, strings.Replace(prefix+f.option, "%", "%%", -1)+": %v", target, f.name)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/grafana/configparser"
+)
+
+func TestGenerate(t *testing.T) {
+	in := `name = demo
+[server]
+host = localhost
+port = 8080
+# type: string
+zip = 01234
+timeout = 30s
+ratio = 0.5
+debug = true
+max-conns = 10
+max_conns = 20
+[log.file]
+path = /var/log/app.log
+[server]
+ignored = 1
+`
+	conf, err := configparser.Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate(conf, "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(src)
+
+	for _, exp := range []string{
+		"package config\n",
+		"type Config struct {\n\tName    string\n\tServer  Server\n\tLogFile LogFile\n}",
+		"\tHost      string\n",
+		"\tPort      int\n",
+		"\tZip       string\n",
+		"\tTimeout   time.Duration\n",
+		"\tRatio     float64\n",
+		"\tDebug     bool\n",
+		"\tMaxConns  int\n",
+		"\tMaxConns2 int\n",
+		"// LogFile holds the options of section [log.file].",
+		`v := value(s, "port", "8080")`,
+		`c.Server.Host = value(s, "host", "localhost")`,
+		`s, _ = conf.Section("log.file")`,
+		`return nil, fmt.Errorf("server.port: %v", err)`,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Fatalf("expected generated code to contain %q, got:\n%s", exp, out)
+		}
+	}
+	if strings.Contains(out, "Ignored") {
+		t.Fatal("expected repeated sections to be ignored")
+	}
+
+	typeCheck(t, src)
+
+	conf, _ = configparser.Read(strings.NewReader("# type: uint\na = 1\n"), "")
+	if _, err := generate(conf, "config"); err == nil {
+		t.Fatal("expected error for unsupported type")
+	}
+}
+
+func TestGenerateEmpty(t *testing.T) {
+	for _, in := range []string{"", "# comment\n[empty]\n[other]\n", "a = 1\n[empty]\n", "[empty]\n[b]\nb = x\n"} {
+		conf, err := configparser.Read(strings.NewReader(in), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		src, err := generate(conf, "config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		typeCheck(t, src)
+	}
+}
+
+// typeCheck fails the test if the generated source doesn't compile
+func typeCheck(t *testing.T, src []byte) {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "config_gen.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("config", fset, []*ast.File{f}, nil); err != nil {
+		t.Fatalf("generated code doesn't compile: %v\n%s", err, src)
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	for in, exp := range map[string]string{
+		"host":           "Host",
+		"max-open_files": "MaxOpenFiles",
+		"log.file":       "LogFile",
+		"2fa":            "X2fa",
+		"":               "X",
+	} {
+		if got := identifier(in); got != exp {
+			t.Fatalf("identifier(%q): expected %q, got %q", in, exp, got)
+		}
+	}
+}
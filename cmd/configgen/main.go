@@ -0,0 +1,54 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command configgen generates typed Go structs, and a Load function to fill them, from an example configuration file.
+//
+// Every section of the example becomes a struct, and every option a field of it. Global options become fields of
+// the top level Config struct. Field types are inferred from the example values (int, float64, bool, time.Duration
+// or string), or set explicitly with a "# type: <type>" comment directly above the option. Example values are used
+// as defaults for options missing from the loaded file.
+//
+// Usage:
+//
+//	//go:generate configgen -in config.example.ini -pkg config -out config_gen.go
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/grafana/configparser"
+)
+
+func main() {
+	in := flag.String("in", "", "example configuration file")
+	pkg := flag.String("pkg", "config", "package name of the generated code")
+	out := flag.String("out", "", "output file (default stdout)")
+	flag.Parse()
+
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	conf, err := configparser.ReadFile(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(conf, *pkg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = ioutil.WriteFile(*out, src, 0644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
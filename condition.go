@@ -0,0 +1,110 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ConditionPrefix introduces a condition after a section header, e.g. "[linux-only] @if os=linux".
+// A condition is a space separated list of var=value and var!=value terms, which must all hold
+// for the section to be included. Conditions are only evaluated if variables are set with WithVariables;
+// unset variables are empty. Sections excluded by their condition are hidden from lookups such as Section
+// and Sections, see ExcludedSections, but are still written by Write and Save, and all sections are written
+// with their condition, so reading and saving a file keeps the sections of every platform.
+const ConditionPrefix = "@if"
+
+// excludedSection is a section excluded by its condition, see ConditionPrefix
+type excludedSection struct {
+	after   *Section // the section it followed when read, nil for the global section
+	section *Section
+}
+
+// Condition returns the condition in the header of the section, e.g. "@if os=linux", or "" if it has none,
+// see ConditionPrefix.
+func (s *Section) Condition() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.condition
+}
+
+// ExcludedSections returns the sections excluded by their condition when the configuration was read,
+// in the order they were read, see ConditionPrefix.
+func (c *Configuration) ExcludedSections() []*Section {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	sections := make([]*Section, len(c.excluded))
+	for i, e := range c.excluded {
+		sections[i] = e.section
+	}
+	return sections
+}
+
+// withExcluded returns the given non-global sections with the sections excluded by their condition in between,
+// each after the section it followed when read, which is how they are written. Excluded sections whose section
+// no longer exists are added at the end.
+func (c *Configuration) withExcluded(sections []*Section) []*Section {
+	c.mutex.RLock()
+	excluded := c.excluded
+	c.mutex.RUnlock()
+	if len(excluded) == 0 {
+		return sections
+	}
+
+	following := make(map[*Section][]*Section)
+	for _, e := range excluded {
+		following[e.after] = append(following[e.after], e.section)
+	}
+	all := following[nil]
+	delete(following, nil)
+	for _, s := range sections {
+		all = append(all, s)
+		all = append(all, following[s]...)
+		delete(following, s)
+	}
+	for _, e := range excluded {
+		if _, ok := following[e.after]; ok {
+			all = append(all, e.section)
+		}
+	}
+	return all
+}
+
+// parseCondition returns the condition following the closing bracket of a section header, if there is any,
+// without the comment after it
+func parseCondition(rest string, opts *options) string {
+	rest = strings.TrimSpace(rest)
+	if pos := opts.commentIndex(rest); pos != -1 {
+		rest = strings.TrimSpace(rest[:pos])
+	}
+	if rest != ConditionPrefix && !strings.HasPrefix(rest, ConditionPrefix+" ") {
+		return ""
+	}
+	return rest
+}
+
+// evalCondition evaluates a condition returned by parseCondition
+func evalCondition(condition string, opts *options) (bool, error) {
+	terms := strings.Fields(strings.TrimPrefix(condition, ConditionPrefix))
+	if len(terms) == 0 {
+		return false, errors.New("empty condition")
+	}
+
+	include := true
+	for _, term := range terms {
+		if i := strings.Index(term, "!="); i > 0 {
+			include = include && opts.variables[term[:i]] != term[i+2:]
+		} else if i := strings.Index(term, "="); i > 0 {
+			include = include && opts.variables[term[:i]] == term[i+1:]
+		} else {
+			return false, fmt.Errorf("invalid condition %q: expected var=value or var!=value", term)
+		}
+	}
+	return include, nil
+}
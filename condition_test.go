@@ -0,0 +1,93 @@
+package configparser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConditionalSections(t *testing.T) {
+	in := `[common]
+a = 1
+[paths] @if os=linux
+data = /var/lib/app
+[paths] @if os=windows # comment
+data = C:\app
+[debug] @if os=linux env!=prod
+level = trace
+[commented] # @if os=windows
+b = 2
+`
+	conf, err := Read(strings.NewReader(in), "", WithVariables(map[string]string{"os": "linux", "env": "prod"}), WithStrictHeaders())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	_, sections, _ := conf.AllSections()
+	for _, s := range sections {
+		names = append(names, s.Name())
+	}
+	if strings.Join(names, ",") != "common,paths,commented" {
+		t.Fatalf("unexpected sections %q", names)
+	}
+	if v, _ := conf.StringValue("paths", "data"); v != "/var/lib/app" {
+		t.Fatalf("unexpected value %q", v)
+	}
+
+	excluded := conf.ExcludedSections()
+	if len(excluded) != 2 || excluded[0].Name() != "paths" || excluded[1].Condition() != "@if os=linux env!=prod" {
+		t.Fatalf("unexpected excluded sections %v", excluded)
+	}
+
+	// excluded sections are written where they were read, and all sections keep their condition
+	exp := strings.NewReplacer(" = ", Delimiter).Replace(`[common]
+a = 1
+[paths] @if os=linux
+data = /var/lib/app
+[paths] @if os=windows
+data = C:\app
+[debug] @if os=linux env!=prod
+level = trace
+[commented]
+b = 2
+`)
+	var buf bytes.Buffer
+	if err := conf.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != exp {
+		t.Fatalf("written config mismatch\nexp %q\ngot %q", exp, buf.String())
+	}
+	buf.Reset()
+	if err := conf.sorted(OrderedLess([]string{"commented"}, nil), nil).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "[commented]") || !strings.Contains(buf.String(), "[paths] @if os=windows") {
+		t.Fatalf("expected excluded sections to be kept when sorting, got %q", buf.String())
+	}
+
+	// without variables, conditions are not evaluated and all sections are included
+	conf, err = Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.NumSections() != 5 || len(conf.ExcludedSections()) != 0 {
+		t.Fatalf("expected 5 sections, got %d", conf.NumSections())
+	}
+	buf.Reset()
+	if err := conf.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != exp {
+		t.Fatalf("written config mismatch\nexp %q\ngot %q", exp, buf.String())
+	}
+
+	for _, in := range []string{"[foo] @if\n", "[foo] @if linux\n", "[foo] @if =linux\n"} {
+		if _, err := Read(strings.NewReader(in), "", WithVariables(map[string]string{})); err == nil {
+			t.Fatalf("%q: expected error for invalid condition", in)
+		}
+		if _, err := Read(strings.NewReader(in), ""); err != nil {
+			t.Fatalf("%q: expected conditions not to be evaluated without variables, got %v", in, err)
+		}
+	}
+}
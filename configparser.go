@@ -21,11 +21,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"path"
+	"math"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"unicode/utf8"
 )
 
 // Delimiter is the delimiter to be used between section key and values when rendering an option string
@@ -33,10 +36,17 @@ var Delimiter = "="
 
 // Configuration represents a configuration file with its sections and options.
 type Configuration struct {
-	filePath        string                // configuration file
+	filePath        string                // configuration file, which Save writes to
+	sourcePath      string                // the file the configuration was read from, which Reload reads
 	global          *Section              // for settings that don't go into a named section
 	sections        map[string]*list.List // fully qualified section name as key. the list serves to support many repeated (same name) sections
 	orderedSections []string              // track the order of section names as they are parsed
+	excluded        []excludedSection     // sections excluded by their condition, see ConditionPrefix
+	pool            *sectionPool          // sections recycled by Reload, see WithReuse
+	reread          rereadFunc            // set for configurations merged from several files
+	shadowed        []shadowedOption      // global options replaced by later files, see ReadFiles
+	profile         string                // active profile, see SetProfile
+	opts            *options
 	mutex           sync.RWMutex
 }
 
@@ -45,25 +55,30 @@ type Section struct {
 	fqn            string
 	isGlobal       bool
 	options        map[string]string
-	orderedOptions []string // track the order of the options as they are parsed
+	orderedOptions []string        // track the order of the options as they are parsed
+	bare           map[string]bool // options that were read without a delimiter, e.g. "opt" as opposed to "opt ="
+	rawLines       []string        // the source lines as they were read, including the header
+	firstLine      int             // the line number of rawLines[0]
+	file           string          // the file the section was read from, see File
+	header         string          // the name in the section header if it differs from fqn, see WithIndexedDuplicates
+	condition      string          // the condition after the section header, see ConditionPrefix
+	lines          map[string]int  // the line number each option was last read from
+	defaults       *Section        // the global section, which interpolation falls back to
+	meta           map[string]string
+	optionMeta     map[string]map[string]string
+	opts           *options
 	mutex          sync.RWMutex
 }
 
 // NewConfiguration returns a new Configuration instance with an empty file path.
 func NewConfiguration() *Configuration {
-	return newConfiguration("")
+	return newConfiguration("", nil)
 }
 
 // ReadFile parses a specified configuration file and returns a Configuration instance.
-func ReadFile(filePath string) (*Configuration, error) {
-	filePath = path.Clean(filePath)
-
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-	return Read(file, filePath)
+// To read many files with the same options, use a Parser.
+func ReadFile(filePath string, opts ...Option) (*Configuration, error) {
+	return NewParser(opts...).ReadFile(filePath)
 }
 
 // findEarliestPos returns the index of substr1 or substr2 whichever is found first, or -1 if neither is found
@@ -93,93 +108,257 @@ func findEarliestPos(s, substr1, substr2 string) int {
 
 // Read reads the given reader into a new Configuration
 // filePath is set for any future persistency but is not used for reading
-func Read(fd io.Reader, filePath string) (*Configuration, error) {
+// opts change how the input is parsed, e.g. Read(r, filePath, WithComments('#', ';'), WithStrictHeaders())
+// To read many files with the same options, use a Parser.
+func Read(fd io.Reader, filePath string, opts ...Option) (*Configuration, error) {
+	return NewParser(opts...).Read(fd, filePath)
+}
+
+// read parses fd using buf as the initial line buffer
+func read(fd io.Reader, filePath string, opts *options, buf []byte, pool *sectionPool) (*Configuration, error) {
 
-	config := newConfiguration(filePath)
+	config := pool.configuration(filePath, opts)
+	config.sourcePath = filePath
 	activeSection := config.global
+	activeSection.file = filePath
+	log := config.opts.log()
+	headers := make(map[string]int) // how often each section name was seen, for WithIndexedDuplicates
+
+	if config.opts.encoding != nil {
+		fd = config.opts.encoding.NewDecoder().Reader(fd)
+	}
 
-	scanner := bufio.NewScanner(bufio.NewReader(fd))
+	lineNum, extraLines := 0, 0
+	lastOption := ""     // the option an indented line continues, see ContinuationIndent
+	var parents []header // enclosing sections of the next header, see HeaderIndentTree
+	var last *Section    // the last section that was included, which excluded sections are written after
+	scanner := bufio.NewScanner(fd)
+	if config.opts.maxValueLen > 0 {
+		// long values are truncated or rejected below, so lines of any length have to be read
+		scanner.Buffer(buf, math.MaxInt32)
+	} else {
+		scanner.Buffer(buf, bufio.MaxScanTokenSize)
+	}
+	if config.opts.backslashContinuation() {
+		scanner.Split(continuedLines)
+	}
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if len(line) < 0 {
+		// lines continued with a backslash are read together, and count as the number of their first line
+		lineNum += 1 + extraLines
+		raw := scanner.Text()
+		extraLines = strings.Count(raw, "\n")
+		line := strings.TrimSpace(raw)
+		if extraLines > 0 {
+			line = strings.TrimSpace(config.opts.joinContinued(raw))
+		}
+
+		if config.opts.continuation == ContinuationIndent && lastOption != "" && line != "" && strings.TrimLeft(raw, " \t") != raw {
+			value := activeSection.options[lastOption] + "\n" + line
+			if max := config.opts.maxValueLen; max > 0 && len(value) > max {
+				if config.opts.longValues == LongValueReject {
+					return nil, parseErrorf(filePath, lineNum, "value of option %q too long: %d bytes, at most %d allowed", lastOption, len(value), max)
+				}
+				log.Warn("truncated long value", "file", filePath, "line", lineNum, "section", activeSection.fqn, "option", lastOption, "bytes", len(value))
+				value = truncate(value, max)
+			}
+			activeSection.options[lastOption] = value
+			delete(activeSection.bare, lastOption)
+			activeSection.rawLines = append(activeSection.rawLines, raw)
 			continue
 		}
+		lastOption = ""
 
 		if isSection(line) {
+			if config.opts.strictHeaders && !isStrictHeader(line, config.opts) {
+				return nil, parseErrorf(filePath, lineNum, "invalid section header %q: expected [name]", line)
+			}
 			line = strings.Trim(line, "[")
 			i := strings.Index(line, "]")
 			if i == -1 {
-				return nil, fmt.Errorf("invalid section header %q", line)
+				return nil, parseErrorf(filePath, lineNum, "invalid section header %q", line)
 			}
 			fqn := line[:i]
-			activeSection = config.addSection(fqn)
+			indent := len(raw) - len(strings.TrimLeft(raw, " \t"))
+			if indent > 0 && config.opts.headerIndent == HeaderIndentReject {
+				return nil, parseErrorf(filePath, lineNum, "invalid section header %q: headers must start at the beginning of the line", line)
+			}
+			if config.opts.headerIndent == HeaderIndentTree {
+				for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+					parents = parents[:len(parents)-1]
+				}
+				if len(parents) > 0 {
+					fqn = parents[len(parents)-1].fqn + HeaderIndentSeparator + fqn
+				}
+			}
+			if config.isGlobalName(config.opts.sectionName(fqn)) {
+				return nil, parseErrorf(filePath, lineNum, "invalid section header %q: %q is the name of the global section", line, fqn)
+			}
+			condition, include := parseCondition(line[i+1:], config.opts), true
+			if condition != "" && config.opts.variables != nil {
+				var err error
+				if include, err = evalCondition(condition, config.opts); err != nil {
+					return nil, parseErrorf(filePath, lineNum, "invalid section header %q: %s", line, err)
+				}
+			}
+			if config.opts.headerIndent == HeaderIndentTree {
+				if len(parents) > 0 {
+					include = include && parents[len(parents)-1].include
+				}
+				parents = append(parents, header{fqn: fqn, indent: indent, include: include})
+			}
+			if !isStrictHeader(strings.TrimSpace(raw), config.opts) {
+				log.Warn("irregular section header", "file", filePath, "line", lineNum, "header", strings.TrimSpace(raw), "section", fqn)
+			}
+			if include && config.opts.duplicates {
+				headers[config.opts.sectionName(fqn)]++
+				if n := headers[config.opts.sectionName(fqn)]; n > 1 {
+					activeSection = pool.addSection(config, fqn+DuplicateSeparator+strconv.Itoa(n))
+					activeSection.header = config.opts.sectionName(fqn)
+				} else {
+					activeSection = pool.addSection(config, fqn)
+				}
+			} else if include {
+				activeSection = pool.addSection(config, fqn)
+			} else {
+				// the section is excluded, its options are still parsed and written, but hidden from lookups
+				log.Debug("skipping section excluded by condition", "file", filePath, "line", lineNum, "section", fqn)
+				activeSection = pool.section(config.opts.sectionName(fqn), false, config.opts)
+				config.excluded = append(config.excluded, excludedSection{after: last, section: activeSection})
+			}
+			if include {
+				last = activeSection
+			}
+			activeSection.condition = condition
+			activeSection.rawLines = append(activeSection.rawLines, raw)
+			activeSection.firstLine = lineNum
+			activeSection.file = filePath
 			continue
 		}
 
 		// [ and ] may not appear after other content (we already checked if it's a prefix above) unless it's in a comment or an option's value
 		posBrack := findEarliestPos(line, "[", "]")
 		if posBrack != -1 {
-			posComment := strings.Index(line, "#")
+			posComment := config.opts.commentIndex(line)
 			if posComment != -1 && posComment < posBrack {
 				// it's in a comment!
 				goto Valid
 			}
-			posVal := strings.Index(line, "=")
+			posVal := config.opts.delimiterIndex(line)
 			if posVal != -1 && posVal < posBrack {
 				// it's in a value!
 				goto Valid
 			}
 
-			return nil, fmt.Errorf("invalid line %q: [ and ] are only allowed in section headers, comments or option values", line)
+			return nil, parseErrorf(filePath, lineNum, "invalid line %q: [ and ] are only allowed in section headers, comments or option values", line)
 		}
 	Valid:
+		if config.opts.strictOptions && line != "" && !config.opts.isComment(line) && config.opts.delimiterIndex(line) == -1 {
+			return nil, parseErrorf(filePath, lineNum, "invalid line %q: expected an option of the form opt=value", line)
+		}
+
+		if line != "" && !config.opts.isComment(line) && isBinary(line) {
+			switch config.opts.binary {
+			case BinaryReject:
+				return nil, parseErrorf(filePath, lineNum, "invalid line %q: NUL bytes or invalid UTF-8", line)
+			case BinaryReplace:
+				line = strings.Replace(strings.ToValidUTF8(line, "\uFFFD"), "\x00", "\uFFFD", -1)
+			}
+		}
+
+		if max := config.opts.maxValueLen; max > 0 && line != "" && !config.opts.isComment(line) {
+			// the value is at the end of the line, which is trimmed
+			if opt, value := config.opts.parseOption(line); len(value) > max {
+				if config.opts.longValues == LongValueReject {
+					return nil, parseErrorf(filePath, lineNum, "value of option %q too long: %d bytes, at most %d allowed", opt, len(value), max)
+				}
+				log.Warn("truncated long value", "file", filePath, "line", lineNum, "section", activeSection.fqn, "option", opt, "bytes", len(value))
+				line = line[:len(line)-len(value)] + truncate(value, max)
+			}
+		}
+
+		if line != "" && !config.opts.isComment(line) {
+			opt, _ := config.opts.parseOption(line)
+			if config.opts.keyWhitespace == KeyWhitespaceReject && collapseWhitespace(opt) != opt {
+				return nil, parseErrorf(filePath, lineNum, "invalid option name %q: only single spaces are allowed inside names", opt)
+			}
+			if _, ok := activeSection.options[config.opts.optionName(opt)]; ok {
+				log.Warn("duplicate option, the last value wins", "file", filePath, "line", lineNum, "section", activeSection.fqn, "option", opt)
+			}
+			if activeSection.lines == nil {
+				activeSection.lines = make(map[string]int)
+			}
+			activeSection.lines[config.opts.optionName(opt)] = lineNum
+			lastOption = config.opts.optionName(opt)
+		}
+
 		// save options and comments
 		addOption(activeSection, line)
+		if len(activeSection.rawLines) == 0 {
+			activeSection.firstLine = lineNum
+		}
+		activeSection.rawLines = append(activeSection.rawLines, raw)
 	}
 
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
 
+	log.Debug("read configuration", "file", filePath, "lines", lineNum+extraLines, "sections", config.NumSections())
+	if err := applySchema(config, config.opts.schema); err != nil {
+		return nil, err
+	}
 	return config, nil
 }
 
-// Save the Configuration to file. Creates a backup (.bak) if file already exists.
-func Save(c *Configuration, filePath string) (err error) {
-	err = os.Rename(filePath, filePath+".bak")
-	if err != nil {
-		if !os.IsNotExist(err) { // fine if the file does not exists
+// SaveSplit partitions the sections of the Configuration across files in dir, see Split,
+// and saves each of them with Save. Nothing is saved if fn returns a name that is absolute
+// or that resolves outside dir, e.g. "../other.conf".
+func SaveSplit(c *Configuration, dir string, fn func(*Section) string) error {
+	parts := c.Split(fn)
+	for _, part := range parts {
+		if !isLocalPath(part.FilePath()) {
+			return fmt.Errorf("can't save split configuration %q outside %s", part.FilePath(), dir)
+		}
+	}
+	for _, part := range parts {
+		err := Save(part, filepath.Join(dir, part.FilePath()))
+		if err != nil {
 			return err
 		}
 	}
+	return nil
+}
 
-	f, err := os.Create(filePath)
-	if err != nil {
-		return err
+// isLocalPath returns true if name is a relative path that stays within the directory it is joined to
+func isLocalPath(name string) bool {
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" || strings.HasPrefix(name, string(filepath.Separator)) {
+		return false
 	}
-
-	defer func() {
-		err = f.Close()
-	}()
-
-	return c.Write(f)
+	name = filepath.Clean(name)
+	return name != "." && name != ".." && !strings.HasPrefix(name, ".."+string(filepath.Separator))
 }
 
 func (c *Configuration) Write(fd io.Writer) error {
+	return c.write(fd, "")
+}
+
+// write writes the Configuration to fd, annotating options with their source if source is set, see Section.format
+func (c *Configuration) write(fd io.Writer, source string) error {
 
 	global, s, err := c.AllSections()
 	if err != nil {
 		return err
 	}
+	s = c.withExcluded(s)
 
 	w := bufio.NewWriter(fd)
 
-	_, err = w.WriteString(global.String())
+	_, err = w.WriteString(global.format(source))
 	if err != nil {
 		return err
 	}
 	for _, v := range s {
-		_, err = w.WriteString(v.String())
+		_, err = w.WriteString(v.format(source))
 		if err != nil {
 			return err
 		}
@@ -187,6 +366,104 @@ func (c *Configuration) Write(fd io.Writer) error {
 	return w.Flush()
 }
 
+// WriteConcat writes the given Configurations one after the other to fd, each one preceded by
+// a "# --- from <source> ---" marker comment, where source is the Configuration's file path.
+// Global options of any but the first Configuration would end up in the last section of the
+// preceding one when the result is read back, so an error is returned for them before anything
+// is written. Their comments and empty lines are allowed.
+func WriteConcat(fd io.Writer, configs ...*Configuration) error {
+	for i, c := range configs {
+		if i == 0 || c.global == nil {
+			continue
+		}
+		for _, opt := range c.global.OptionNames() {
+			if opt != "" && !c.opts.isComment(opt) {
+				return fmt.Errorf("global option %s of %s can't follow the sections of another configuration", opt, concatSource(c))
+			}
+		}
+	}
+
+	w := bufio.NewWriter(fd)
+	for _, c := range configs {
+		source := concatSource(c)
+		_, err := w.WriteString("# --- from " + source + " ---\n")
+		if err != nil {
+			return err
+		}
+		err = c.Write(w)
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// concatSource returns the name of c in the markers written by WriteConcat
+func concatSource(c *Configuration) string {
+	if source := c.FilePath(); source != "" {
+		return source
+	}
+	return "(unnamed)"
+}
+
+// rereadFunc reads a configuration merged from several files again, the way it was first read, see Reload
+type rereadFunc func() (*Configuration, error)
+
+// Reload re-reads the configuration file at SourcePath in place, with the options it was originally read with.
+// A configuration merged from several files by ReadFileWithOverlay or ReadFiles is read again the same way,
+// from the same files.
+// Settings of the Configuration itself, such as the active profile, are kept.
+// Sections obtained before the reload are detached from the Configuration and no longer reflect it,
+// or, if it was read WithReuse from a single file, are recycled and must no longer be used.
+// If reading fails, an error is returned and the Configuration is left unchanged.
+func (c *Configuration) Reload() (err error) {
+	span := c.opts.trace().Start("configparser.Reload")
+	span.SetAttribute("file", c.SourcePath())
+	defer func() { span.End(err) }()
+
+	// the pool is taken for the read, so concurrent reloads don't share it
+	c.mutex.Lock()
+	pool, reread := c.pool, c.reread
+	c.pool = nil
+	c.mutex.Unlock()
+
+	var fresh *Configuration
+	switch {
+	case reread != nil:
+		fresh, err = reread()
+	case c.SourcePath() == "":
+		err = errors.New("configuration was not read from a file to reload")
+	default:
+		fresh, err = newParser(c.opts).readFile(c.SourcePath(), pool)
+	}
+	if err != nil {
+		c.mutex.Lock()
+		c.pool = pool
+		c.mutex.Unlock()
+		return err
+	}
+	span.SetAttribute("sections", fresh.NumSections())
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	global, sections, excluded := c.global, c.sections, c.excluded
+	c.global = fresh.global
+	c.sections = fresh.sections
+	c.orderedSections = fresh.orderedSections
+	c.excluded = fresh.excluded
+	c.shadowed = fresh.shadowed
+	// merged configurations are read with new sections, which a pool would only accumulate
+	if c.opts != nil && c.opts.reuse && reread == nil {
+		if pool == nil {
+			pool = &sectionPool{}
+		}
+		pool.release(global, sections, excluded)
+		c.pool = pool
+	}
+	return nil
+}
+
 // NewSection creates and adds a new non-global Section with the specified name.
 func (c *Configuration) NewSection(fqn string) *Section {
 	return c.addSection(fqn)
@@ -194,10 +471,14 @@ func (c *Configuration) NewSection(fqn string) *Section {
 
 // FilePath returns the configuration file path.
 func (c *Configuration) FilePath() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
 	return c.filePath
 }
 
-// SetFilePath sets the Configuration file path.
+// SetFilePath sets the Configuration file path, which Save writes to. The path Reload reads from is
+// not changed, see SourcePath.
 func (c *Configuration) SetFilePath(filePath string) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
@@ -205,8 +486,44 @@ func (c *Configuration) SetFilePath(filePath string) {
 	c.filePath = filePath
 }
 
+// SourcePath returns the path the Configuration was read from, which Reload reads again, or "" if it
+// wasn't read from a file path. Unlike FilePath, it can't be changed.
+func (c *Configuration) SourcePath() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.sourcePath
+}
+
+// ProfileSeparator separates a section name from a profile name in profile-scoped sections, e.g. [db:staging].
+const ProfileSeparator = ":"
+
+// SetProfile sets the active profile. While a profile is active, StringValue looks up options in the
+// profile-scoped section "<section>:<profile>" first, and falls back to the unscoped section if the
+// scoped section or the option in it doesn't exist. An empty profile disables this.
+func (c *Configuration) SetProfile(profile string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.profile = profile
+}
+
+// Profile returns the active profile, see SetProfile.
+func (c *Configuration) Profile() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.profile
+}
+
 // StringValue returns the string value for the specified non-global section and option.
+// If a profile is active, the value in the profile-scoped section takes precedence, see SetProfile.
 func (c *Configuration) StringValue(section, option string) (value string, err error) {
+	if profile := c.Profile(); profile != "" {
+		if s, err := c.Section(section + ProfileSeparator + profile); err == nil && s.Exists(option) {
+			return s.ValueOf(option), nil
+		}
+	}
 	s, err := c.Section(section)
 	if err != nil {
 		return
@@ -215,6 +532,63 @@ func (c *Configuration) StringValue(section, option string) (value string, err e
 	return
 }
 
+// SetValue sets the value for the specified option in the first non-global section with the given name,
+// adding the option if it doesn't exist yet, see Section.Add. The old value is returned.
+// An error is returned if the section doesn't exist.
+func (c *Configuration) SetValue(section, option, value string) (oldValue string, err error) {
+	s, err := c.Section(section)
+	if err != nil {
+		return "", err
+	}
+	return s.Add(option, value), nil
+}
+
+// DeleteOption removes the specified option from the first non-global section with the given name
+// and returns the deleted option's value. An error is returned if the section or the option doesn't exist.
+func (c *Configuration) DeleteOption(section, option string) (value string, err error) {
+	s, err := c.Section(section)
+	if err != nil {
+		return "", err
+	}
+	if !s.Exists(option) {
+		return "", fmt.Errorf("Unable to find option %s in section %s", option, section)
+	}
+	return s.Delete(option), nil
+}
+
+// PromoteOption moves an option from the first non-global section with the given name to the global section,
+// see Section.MoveOption. An error is returned if the section or the option doesn't exist, or if it is constant.
+func (c *Configuration) PromoteOption(section, option string) error {
+	s, err := c.Section(section)
+	if err != nil {
+		return err
+	}
+	if !s.Exists(option) {
+		return fmt.Errorf("Unable to find option %s in section %s", option, section)
+	}
+	if !s.MoveOption(option, c.global) {
+		return fmt.Errorf("option %s is constant", option)
+	}
+	return nil
+}
+
+// DemoteOption moves an option from the global section to the first non-global section with the given name,
+// which is added if it doesn't exist, see Section.MoveOption. An error is returned if the option doesn't exist
+// or if it is constant.
+func (c *Configuration) DemoteOption(option, section string) error {
+	if !c.global.Exists(option) {
+		return fmt.Errorf("Unable to find option %s in the global section", option)
+	}
+	s, err := c.Section(section)
+	if err != nil {
+		s = c.NewSection(section)
+	}
+	if !c.global.MoveOption(option, s) {
+		return fmt.Errorf("option %s is constant", option)
+	}
+	return nil
+}
+
 // Delete deletes the specified non-global sections matched by a regex name and returns the deleted sections.
 func (c *Configuration) Delete(regex string) (sections []*Section, err error) {
 	sections, err = c.Find(regex)
@@ -245,11 +619,44 @@ func (c *Configuration) GlobalSection() *Section {
 	return c.global
 }
 
-// Section returns the first non-global section matching the fully qualified section name.
+// SectionsWithHeader returns the non-global sections whose header has the given name, see HeaderName,
+// in the order they were read. For a file read with WithIndexedDuplicates, these are the sections "foo",
+// "foo#2", "foo#3" and so on for the name "foo".
+func (c *Configuration) SectionsWithHeader(name string) []*Section {
+	sections, _ := c.Sections("")
+	name = c.opts.sectionName(name)
+	var found []*Section
+	for _, s := range sections {
+		if s.HeaderName() == name {
+			found = append(found, s)
+		}
+	}
+	return found
+}
+
+// GlobalName returns the name of the global section, which is empty unless set with WithGlobalName.
+func (c *Configuration) GlobalName() string {
+	if c.global == nil {
+		return ""
+	}
+	return c.global.Name()
+}
+
+// isGlobalName returns true if fqn is the name of the global section set with WithGlobalName
+func (c *Configuration) isGlobalName(fqn string) bool {
+	return fqn != "" && c.global != nil && fqn == c.global.fqn
+}
+
+// Section returns the first non-global section matching the fully qualified section name,
+// or the global section if it has the name set with WithGlobalName.
 func (c *Configuration) Section(fqn string) (*Section, error) {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
+	fqn = c.opts.sectionName(fqn)
+	if c.isGlobalName(fqn) {
+		return c.global, nil
+	}
 	if l, ok := c.sections[fqn]; ok {
 		for e := l.Front(); e != nil; e = e.Next() {
 			s := e.Value.(*Section)
@@ -265,36 +672,13 @@ func (c *Configuration) AllSections() (*Section, []*Section, error) {
 	return c.global, s, err
 }
 
-// Sections returns a slice of non-global Sections matching the fully qualified section name.
+// Sections returns a slice of non-global Sections matching the fully qualified section name,
+// or the global section if it has the name set with WithGlobalName.
 func (c *Configuration) Sections(fqn string) ([]*Section, error) {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	var sections []*Section
-
-	f := func(lst *list.List) {
-		for e := lst.Front(); e != nil; e = e.Next() {
-			s := e.Value.(*Section)
-			sections = append(sections, s)
-		}
-	}
-
-	if fqn == "" {
-		// Get all sections.
-		for _, fqn := range c.orderedSections {
-			if lst, ok := c.sections[fqn]; ok {
-				f(lst)
-			}
-		}
-	} else {
-		if lst, ok := c.sections[fqn]; ok {
-			f(lst)
-		} else {
-			return nil, errors.New("Unable to find " + fqn)
-		}
-	}
-
-	return sections, nil
+	return c.lookupSections(fqn)
 }
 
 // Find returns a slice of non-global Sections matching the regexp against the section name.
@@ -318,12 +702,66 @@ func (c *Configuration) Find(regex string) ([]*Section, error) {
 	return sections, nil
 }
 
+// Extract returns a new, independent Configuration holding copies of all non-global sections with the given names,
+// including their comments, in the order they appear in c. Names that don't match any section are ignored.
+func (c *Configuration) Extract(names ...string) *Configuration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[c.opts.sectionName(name)] = true
+	}
+
+	extracted := newConfiguration("", c.opts)
+	for _, fqn := range c.orderedSections {
+		lst, ok := c.sections[fqn]
+		if !ok || !wanted[fqn] {
+			continue
+		}
+		for e := lst.Front(); e != nil; e = e.Next() {
+			extracted.insertSection(e.Value.(*Section).copy())
+		}
+	}
+	return extracted
+}
+
+// Split partitions the Configuration into new, independent Configurations.
+// fn is called for every section, including the global one, and returns the name of the
+// Configuration the section is copied into. That name is also set as its file path.
+// Sections for which fn returns "" are left out.
+// The Configurations are returned in the order in which fn first returned their name.
+func (c *Configuration) Split(fn func(*Section) string) []*Configuration {
+	global, sections, _ := c.AllSections()
+
+	var parts []*Configuration
+	byName := make(map[string]*Configuration)
+	for _, s := range append([]*Section{global}, sections...) {
+		name := fn(s)
+		if name == "" {
+			continue
+		}
+		part, ok := byName[name]
+		if !ok {
+			part = newConfiguration(name, c.opts)
+			byName[name] = part
+			parts = append(parts, part)
+		}
+		if s.isGlobal {
+			part.global = s.copy()
+		} else {
+			part.insertSection(s.copy())
+		}
+	}
+	return parts
+}
+
 // PrintSection prints a text representation of all non-global sections matching the fully qualified section name.
 func (c *Configuration) PrintSection(fqn string) error {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	sections, err := c.Sections(fqn)
+	sections, err := c.lookupSections(fqn)
 	if err != nil {
 		return err
 	}
@@ -333,22 +771,68 @@ func (c *Configuration) PrintSection(fqn string) error {
 	return nil
 }
 
-// String returns the text representation of a parsed configuration file.
+// String returns the text representation of a parsed configuration file: the global options followed by
+// the sections in declaration order, with repeated sections following the first one of the same name,
+// and each option on its own line as "opt = value". The output only depends on the sections and options,
+// not on how they were formatted in the source, so it is stable for logging and comparing in tests.
 func (c *Configuration) String() string {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	var parts []string
-	parts = append(parts, c.global.String())
-	for _, fqn := range c.orderedSections {
-		sections, _ := c.Sections(fqn)
-		for _, section := range sections {
-			parts = append(parts, section.String())
-		}
+	sections, _ := c.lookupSections("")
+	parts := []string{c.global.String()}
+	for _, section := range sections {
+		parts = append(parts, section.String())
 	}
 	return strings.Join(parts, "")
 }
 
+// NumSections returns the number of non-global sections, counting repeated sections individually.
+func (c *Configuration) NumSections() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	n := 0
+	for _, lst := range c.sections {
+		n += lst.Len()
+	}
+	return n
+}
+
+// Size returns the approximate size in bytes of the configuration when written, see String.
+func (c *Configuration) Size() int {
+	return len(c.String())
+}
+
+// ValueSize is the size of the value of an option, see LargestValues.
+type ValueSize struct {
+	Section string
+	Option  string
+	Size    int // in bytes
+	Line    int // see LineOf
+}
+
+// LargestValues returns the n largest values of all sections, including the global section, largest first,
+// e.g. to find values that were pasted by accident. Values of the same size are kept in file order.
+// A negative n returns all values.
+func (c *Configuration) LargestValues(n int) []ValueSize {
+	global, sections, _ := c.AllSections()
+	var sizes []ValueSize
+	for _, s := range append([]*Section{global}, sections...) {
+		for _, opt := range s.OptionNames() {
+			if opt == "" || s.opts.isComment(opt) {
+				continue
+			}
+			sizes = append(sizes, ValueSize{Section: s.Name(), Option: opt, Size: len(s.RawValueOf(opt)), Line: s.LineOf(opt)})
+		}
+	}
+	sort.SliceStable(sizes, func(i, j int) bool { return sizes[i].Size > sizes[j].Size })
+	if n >= 0 && len(sizes) > n {
+		sizes = sizes[:n]
+	}
+	return sizes
+}
+
 // Name returns the name of the section
 func (s *Section) Name() string {
 	s.mutex.Lock()
@@ -357,58 +841,145 @@ func (s *Section) Name() string {
 	return s.fqn
 }
 
+// File returns the path of the file the section was read from, or "" if it wasn't read from a file.
+// For configurations read from several files with ReadFiles, this is where SaveFiles writes the section.
+func (s *Section) File() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.file
+}
+
+// HeaderName returns the name of the section as written in its header. It only differs from Name for
+// repeated sections read with WithIndexedDuplicates, e.g. "foo" for the section named "foo#2".
+func (s *Section) HeaderName() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.header != "" {
+		return s.header
+	}
+	return s.fqn
+}
+
 // Exists returns true if the option exists
 func (s *Section) Exists(option string) (ok bool) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
+	option = s.opts.optionName(option)
 	_, ok = s.options[option]
 	return
 }
 
 // ValueOf returns the value of specified option.
+// If the Configuration was read WithInterpolation, references to other options are expanded,
+// see InterpolatedValueOf, and then the transformers of WithTransformers are applied.
+// Values that fail to interpolate or transform are returned as-is.
 func (s *Section) ValueOf(option string) string {
+	return s.maybeTransform(option, s.maybeInterpolate(option, s.RawValueOf(option)))
+}
+
+// RawValueOf returns the value of specified option as it was read or set, without any interpolation.
+func (s *Section) RawValueOf(option string) string {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	option = s.opts.optionName(option)
 	return s.options[option]
 }
 
-// ValueOf returns the value of specified option without any trailing comments (denoted by ' #')
+// ValueOfWithoutComments returns the value of specified option without any trailing comments (denoted by ' #', see WithComments)
 func (s *Section) ValueOfWithoutComments(option string) string {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	val := s.options[option]
-	pos := strings.Index(val, "#")
+	val := s.RawValueOf(option)
+	pos := s.opts.commentIndex(val)
 	if pos != -1 {
 		val = val[:pos]
 	}
-	return strings.TrimSpace(val)
+	return s.maybeTransform(option, strings.TrimSpace(s.maybeInterpolate(option, val)))
+}
+
+// UnescapedValueOf returns the value of the specified option with backslash escape sequences,
+// such as \n, \t, \\ and \uXXXX, interpreted into the characters they represent.
+// The value itself, as returned by ValueOf and written by Save, is left untouched.
+// An error is returned if the value contains an invalid escape sequence.
+func (s *Section) UnescapedValueOf(option string) (string, error) {
+	return unescape(s.ValueOf(option))
 }
 
 // SetValueFor sets the value for the specified option and returns the old value.
+// Unlike Add, an empty value is written with a delimiter, as "opt =", see State.
 func (s *Section) SetValueFor(option string, value string) string {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	option = s.opts.optionName(option)
+	s.init()
 	oldValue := s.options[option]
+	if s.keepConstant(option) {
+		return oldValue
+	}
 	s.options[option] = value
+	delete(s.bare, option)
 
 	return oldValue
 }
 
+// OptionState describes if, and how, an option is set in a section.
+type OptionState int
+
+const (
+	// StateUnset means the option does not exist.
+	StateUnset OptionState = iota
+	// StateBare means the option exists without a delimiter or value, like "opt".
+	StateBare
+	// StateEmpty means the option exists with a delimiter but an empty value, like "opt =".
+	StateEmpty
+	// StateSet means the option has a non-empty value, like "opt = value".
+	StateSet
+)
+
+// State returns the state of the specified option, distinguishing between missing, bare and empty options,
+// which all have an empty string as value.
+func (s *Section) State(option string) OptionState {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	option = s.opts.optionName(option)
+	value, ok := s.options[option]
+	switch {
+	case !ok:
+		return StateUnset
+	case value != "":
+		return StateSet
+	case s.bare[option]:
+		return StateBare
+	}
+	return StateEmpty
+}
+
 // Add adds a new option to the section. Adding an existing option will overwrite the old one.
-// The old value is returned
+// The old value is returned. An option added with an empty value is bare, and written as "opt"
+// like options without a value in the source, see State; use SetValueFor to write it as "opt =".
 func (s *Section) Add(option string, value string) (oldValue string) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	option = s.opts.optionName(option)
+	s.init()
+	if s.keepConstant(option) {
+		return s.options[option]
+	}
 	var ok bool
 	if oldValue, ok = s.options[option]; !ok {
 		s.orderedOptions = append(s.orderedOptions, option)
 	}
 	s.options[option] = value
+	if value == "" {
+		s.bare[option] = true
+	} else {
+		delete(s.bare, option)
+	}
 
 	return oldValue
 }
@@ -418,16 +989,190 @@ func (s *Section) Delete(option string) (value string) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	option = s.opts.optionName(option)
 	value = s.options[option]
+	if s.keepConstant(option) {
+		return value
+	}
 	delete(s.options, option)
-	for i, opt := range s.orderedOptions {
-		if opt == option {
+	delete(s.bare, option)
+	delete(s.optionMeta, option)
+	delete(s.lines, option)
+	for i := len(s.orderedOptions) - 1; i >= 0; i-- {
+		if s.orderedOptions[i] == option {
 			s.orderedOptions = append(s.orderedOptions[:i], s.orderedOptions[i+1:]...)
 		}
 	}
 	return value
 }
 
+// Clear removes all options, including comments and empty lines, from the section, e.g. to clear the global
+// section of a configuration. The section itself and its metadata are kept, as are constants, see MarkConstant,
+// which are left as they are and logged like Delete does.
+func (s *Section) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var kept []string
+	for _, opt := range s.orderedOptions {
+		if s.keepConstant(opt) {
+			kept = append(kept, opt)
+		}
+	}
+	c := newSection(s.fqn, s.isGlobal, s.opts)
+	for _, opt := range kept {
+		s.copyOption(c, opt)
+	}
+	s.options = c.options
+	s.bare = c.bare
+	s.orderedOptions = c.orderedOptions
+	s.optionMeta = c.optionMeta
+	s.lines = c.lines
+}
+
+// ReplaceWith replaces all options of the section, including comments, with copies of those of src,
+// keeping the name and metadata of the section, e.g. to replace the global options of one configuration
+// with those of another. Constants, see MarkConstant, keep their values and metadata, at their position
+// in src or after its options, and changes to them are logged like Add does.
+func (s *Section) ReplaceWith(src *Section) {
+	c := src.copy()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, opt := range s.orderedOptions {
+		if !s.isConstant(opt) {
+			continue
+		}
+		if value, ok := c.options[opt]; !ok || value != s.options[opt] || c.bare[opt] != s.bare[opt] {
+			s.keepConstant(opt)
+		}
+		s.copyOption(c, opt)
+	}
+	s.options = c.options
+	s.bare = c.bare
+	s.orderedOptions = c.orderedOptions
+	s.optionMeta = c.optionMeta
+	s.lines = c.lines
+}
+
+// copyOption sets the value, state, metadata and line of the option, as returned by optionName, in dst to
+// those in s, adding it after the options of dst if it doesn't have it. The caller must hold the lock of s,
+// dst must not be in use yet.
+func (s *Section) copyOption(dst *Section, option string) {
+	if _, ok := dst.options[option]; !ok {
+		dst.orderedOptions = append(dst.orderedOptions, option)
+	}
+	dst.options[option] = s.options[option]
+	if s.bare[option] {
+		dst.bare[option] = true
+	} else {
+		delete(dst.bare, option)
+	}
+	if meta, ok := s.optionMeta[option]; ok {
+		if dst.optionMeta == nil {
+			dst.optionMeta = make(map[string]map[string]string)
+		}
+		dst.optionMeta[option] = meta
+	}
+	if n, ok := s.lines[option]; ok {
+		if dst.lines == nil {
+			dst.lines = make(map[string]int)
+		}
+		dst.lines[option] = n
+	}
+}
+
+// MoveOption moves an option, with its value and metadata, to the end of section to, replacing an option with
+// the same name there, and returns whether it was moved: options that don't exist, are constant or would
+// replace a constant are not moved, see MarkConstant. See Configuration.PromoteOption and DemoteOption for
+// moving options between the global section and other sections.
+func (s *Section) MoveOption(option string, to *Section) bool {
+	if s == to {
+		return s.Exists(option)
+	}
+	if s.IsConstant(option) || to.IsConstant(option) {
+		return false
+	}
+
+	s.mutex.Lock()
+	option = s.opts.optionName(option)
+	value, ok := s.options[option]
+	bare, meta := s.bare[option], s.optionMeta[option]
+	s.mutex.Unlock()
+	if !ok {
+		return false
+	}
+	s.Delete(option)
+
+	to.Delete(option)
+	to.Add(option, value)
+	to.mutex.Lock()
+	defer to.mutex.Unlock()
+
+	option = to.opts.optionName(option)
+	if bare {
+		to.bare[option] = true
+	} else {
+		delete(to.bare, option)
+	}
+	if meta != nil {
+		if to.optionMeta == nil {
+			to.optionMeta = make(map[string]map[string]string)
+		}
+		to.optionMeta[option] = meta
+	}
+	return true
+}
+
+// RenameOption renames an option, keeping its value, position and metadata, and returns whether it was renamed.
+// An option isn't renamed if it doesn't exist, is constant, see MarkConstant, or if the section already has an
+// option with the new name.
+func (s *Section) RenameOption(old, new string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	old, new = s.opts.optionName(old), s.opts.optionName(new)
+	if _, ok := s.options[old]; !ok {
+		return false
+	}
+	if old == new {
+		return true
+	}
+	if _, ok := s.options[new]; ok || s.isConstant(old) {
+		return false
+	}
+
+	s.options[new] = s.options[old]
+	delete(s.options, old)
+	if s.bare[old] {
+		s.bare[new] = true
+		delete(s.bare, old)
+	}
+	if m, ok := s.optionMeta[old]; ok {
+		s.optionMeta[new] = m
+		delete(s.optionMeta, old)
+	}
+	if n, ok := s.lines[old]; ok {
+		s.lines[new] = n
+		delete(s.lines, old)
+	}
+	for i, name := range s.orderedOptions {
+		if name == old {
+			s.orderedOptions[i] = new
+		}
+	}
+	return true
+}
+
+// LineOf returns the line number the option was read from, or 0 if it wasn't read from a source.
+// If the option was repeated, the line of its last occurrence is returned.
+func (s *Section) LineOf(option string) int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.lines[s.opts.optionName(option)]
+}
+
 // Options returns a map of options for the section.
 func (s *Section) Options() map[string]string {
 	return s.options
@@ -438,23 +1183,230 @@ func (s *Section) OptionNames() []string {
 	return s.orderedOptions
 }
 
-// String returns the text representation of a section with its options.
+// RawLines returns the source lines of the section exactly as they were read, including the section header,
+// comments and blank lines. Sections that were not read from a source, or the lines added to them afterwards,
+// are not included.
+func (s *Section) RawLines() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	lines := make([]string, len(s.rawLines))
+	copy(lines, s.rawLines)
+	return lines
+}
+
+// WriteTo writes the canonical text representation of the section, as returned by String, to w.
+func (s *Section) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, s.String())
+	return int64(n), err
+}
+
+// WriteRawTo writes the section to w exactly as it was read, see RawLines.
+// Changes made to the section after reading are not reflected. Sections without
+// any raw lines are written in their canonical form instead.
+func (s *Section) WriteRawTo(w io.Writer) (int64, error) {
+	lines := s.RawLines()
+	if len(lines) == 0 {
+		return s.WriteTo(w)
+	}
+	n, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return int64(n), err
+}
+
+// SetMeta attaches caller-defined metadata to the section, e.g. validation state or UI hints.
+// Metadata is kept in memory and copied along with the section, but never written out.
+func (s *Section) SetMeta(key, value string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.meta == nil {
+		s.meta = make(map[string]string)
+	}
+	s.meta[key] = value
+}
+
+// Meta returns the metadata value for key attached to the section, see SetMeta.
+func (s *Section) Meta(key string) string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.meta[key]
+}
+
+// SetOptionMeta attaches caller-defined metadata to the specified option, see SetMeta.
+// The metadata is removed when the option is deleted.
+func (s *Section) SetOptionMeta(option, key, value string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	option = s.opts.optionName(option)
+	if s.optionMeta == nil {
+		s.optionMeta = make(map[string]map[string]string)
+	}
+	if s.optionMeta[option] == nil {
+		s.optionMeta[option] = make(map[string]string)
+	}
+	s.optionMeta[option][key] = value
+}
+
+// OptionMeta returns the metadata value for key attached to the specified option, see SetOptionMeta.
+func (s *Section) OptionMeta(option, key string) string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	option = s.opts.optionName(option)
+	return s.optionMeta[option][key]
+}
+
+// NumOptions returns the number of options in the section. Note that comments and empty lines are counted as options.
+func (s *Section) NumOptions() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.orderedOptions)
+}
+
+// Tree returns the options of the section folded into nested maps at each ".", e.g. options limits.cpu and
+// limits.mem become map[string]interface{}{"limits": map[string]interface{}{"cpu": ..., "mem": ...}}.
+// Leaves are the string values, see Sub. If an option is both a value and a prefix of other options,
+// like limits and limits.cpu, its value is kept under the "" key of the nested map. Comments and empty lines
+// are left out.
+func (s *Section) Tree() map[string]interface{} {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	tree := make(map[string]interface{})
+	for _, opt := range s.orderedOptions {
+		if opt == "" || s.opts.isComment(opt) {
+			continue
+		}
+		parts := strings.Split(opt, ".")
+		node := tree
+		for _, part := range parts[:len(parts)-1] {
+			switch child := node[part].(type) {
+			case map[string]interface{}:
+				node = child
+			case string:
+				node[part] = map[string]interface{}{"": child}
+				node = node[part].(map[string]interface{})
+			default:
+				next := make(map[string]interface{})
+				node[part] = next
+				node = next
+			}
+		}
+		last := parts[len(parts)-1]
+		if child, ok := node[last].(map[string]interface{}); ok {
+			child[""] = s.options[opt]
+		} else {
+			node[last] = s.options[opt]
+		}
+	}
+	return tree
+}
+
+// Sub returns a virtual section holding the options of this section that are prefixed with "prefix.",
+// with the prefix removed, e.g. for options db.host and db.port, s.Sub("db").ValueOf("host").
+// The returned section is a detached copy named "<section>.<prefix>"; changes to it don't affect this section.
+func (s *Section) Sub(prefix string) *Section {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	name := prefix
+	if s.fqn != "" {
+		name = s.fqn + "." + prefix
+	}
+	sub := newSection(name, false, s.opts)
+	sub.defaults = s.defaults
+	prefix = s.opts.optionName(prefix) + "."
+	for _, opt := range s.orderedOptions {
+		if !strings.HasPrefix(opt, prefix) {
+			continue
+		}
+		key := strings.TrimPrefix(opt, prefix)
+		if _, ok := sub.options[key]; !ok {
+			sub.orderedOptions = append(sub.orderedOptions, key)
+		}
+		sub.options[key] = s.options[opt]
+		if s.bare[opt] {
+			sub.bare[key] = true
+		}
+	}
+	return sub
+}
+
+// KeyAt returns the name of the option at index i, in the same order as they were parsed, and whether i is
+// in range. Like NumOptions, comments and empty lines are counted as options: a comment is returned as its
+// text before any delimiter, and an empty line as "".
+func (s *Section) KeyAt(i int) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if i < 0 || i >= len(s.orderedOptions) {
+		return "", false
+	}
+	return s.orderedOptions[i], true
+}
+
+// OptionAt returns the name and value of the option at index i, and whether i is in range, see KeyAt and ValueOf.
+func (s *Section) OptionAt(i int) (option, value string, ok bool) {
+	if option, ok = s.KeyAt(i); !ok {
+		return "", "", false
+	}
+	return option, s.ValueOf(option), true
+}
+
+// String returns the text representation of a section with its options, in declaration order.
+// Spaces around option names and values are trimmed, as they would be when the text is read back.
 func (s *Section) String() string {
+	return s.format("")
+}
+
+// provenancePrefix starts the comments written above options by WithProvenance
+const provenancePrefix = " from: "
+
+// format returns the text representation of the section, see String. If source is set, each option
+// is preceded by a comment naming its source, see WithProvenance, which is the source recorded in its
+// metadata or else source itself. Such comments written earlier are left out.
+func (s *Section) format(source string) string {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
 	var parts []string
+	prefix := s.opts.commentChar() + provenancePrefix
 
 	if !s.isGlobal {
-		parts = append(parts, "["+s.fqn+"]\n")
+		header := "[" + s.fqn + "]"
+		if s.header != "" {
+			header = "[" + s.header + "]"
+		}
+		if s.condition != "" {
+			header += " " + s.condition
+		}
+		parts = append(parts, header+"\n")
 	}
 
-	for _, opt := range s.orderedOptions {
-		value := s.options[opt]
+	for _, name := range s.orderedOptions {
+		opt, value := strings.Trim(name, " "), strings.Trim(s.options[name], " ")
+		if source != "" && strings.HasPrefix(opt, prefix) {
+			continue
+		}
+		if source != "" && opt != "" && !s.opts.isComment(opt) {
+			from := s.optionMeta[name][metaSource]
+			if from == "" {
+				from = source
+			}
+			parts = append(parts, prefix, from, "\n")
+		}
+		if s.opts != nil && s.opts.continuation == ContinuationIndent {
+			value = strings.Replace(value, "\n", "\n\t", -1)
+		}
 		if value != "" {
 			parts = append(parts, opt, Delimiter, value, "\n")
-		} else {
+		} else if s.bare[name] {
 			parts = append(parts, opt, "\n")
+		} else {
+			parts = append(parts, opt, strings.TrimRight(Delimiter, " "), "\n")
 		}
 	}
 
@@ -466,30 +1418,77 @@ func (s *Section) String() string {
 //
 
 // newSection creates a new, blank section
-func newSection(fqn string, isGlobal bool) *Section {
+func newSection(fqn string, isGlobal bool, opts *options) *Section {
 	return &Section{
 		fqn:      fqn,
 		isGlobal: isGlobal,
 		options:  make(map[string]string),
+		bare:     make(map[string]bool),
+		opts:     opts,
 	}
 }
 
 // newConfiguration creates a new Configuration instance.
-func newConfiguration(filePath string) *Configuration {
+func newConfiguration(filePath string, opts *options) *Configuration {
+	if opts == nil {
+		opts = &options{}
+	}
 	return &Configuration{
 		filePath: filePath,
-		global:   newSection("", true),
+		global:   newSection(opts.sectionName(opts.globalName), true, opts),
 		sections: make(map[string]*list.List),
+		opts:     opts,
 	}
 }
 
+// header is an enclosing section of a nested section, see HeaderIndentTree
+type header struct {
+	fqn     string
+	indent  int
+	include bool
+}
+
+// truncate cuts value down to at most max bytes, without splitting UTF-8 sequences, see WithMaxValueLength
+func truncate(value string, max int) string {
+	for max > 0 && !utf8.RuneStart(value[max]) {
+		max--
+	}
+	return value[:max]
+}
+
+// parseErrorf returns an error located at the given line of the file being read
+func parseErrorf(filePath string, lineNum int, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if filePath == "" {
+		return fmt.Errorf("line %d: %s", lineNum, msg)
+	}
+	return fmt.Errorf("%s:%d: %s", filePath, lineNum, msg)
+}
+
 func isSection(section string) bool {
 	return strings.HasPrefix(section, "[")
 }
 
+// isStrictHeader returns true if line is exactly "[name]", optionally followed by a condition and/or a comment,
+// where name does not contain brackets
+func isStrictHeader(line string, opts *options) bool {
+	end := strings.Index(line, "]")
+	if end == -1 || strings.ContainsAny(line[1:end], "[]") {
+		return false
+	}
+	rest := strings.TrimSpace(line[end+1:])
+	return rest == "" || opts.isComment(rest) || strings.HasPrefix(rest, ConditionPrefix+" ")
+}
+
 func addOption(s *Section, option string) {
-	opt, value := parseOption(option)
+	opt, value := s.opts.parseOption(option)
+	opt = s.opts.optionName(opt)
 	s.options[opt] = value
+	if s.opts.delimiterIndex(option) != -1 {
+		delete(s.bare, opt)
+	} else {
+		s.bare[opt] = true
+	}
 
 	s.orderedOptions = append(s.orderedOptions, opt)
 }
@@ -497,34 +1496,163 @@ func addOption(s *Section, option string) {
 // parseOption parses a string like "opt=value", "opt:value" or "opt", removing extraneous whitespace
 // (in the 3rd case only opt is set and value is "")
 func parseOption(option string) (opt, value string) {
+	return (*options)(nil).parseOption(option)
+}
 
-	split := func(i int, delim string) (opt, value string) {
+// parseOption splits an option line at the first delimiter, see Dialect.Delimiters
+func (o *options) parseOption(option string) (opt, value string) {
+
+	split := func(i int) (opt, value string) {
 		// strings.Split cannot handle wsrep_provider_options settings
 		opt = strings.Trim(option[:i], " ")
 		value = strings.Trim(option[i+1:], " ")
 		return
 	}
 
-	if i := strings.Index(option, "="); i != -1 {
-		opt, value = split(i, "=")
+	if i := o.delimiterIndex(option); i != -1 {
+		opt, value = split(i)
 	} else {
 		opt = option
 	}
 	return
 }
 
+// copyMeta returns a copy of a metadata map
+func copyMeta(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// unescape interprets the Go-style backslash escape sequences in value
+func unescape(value string) (string, error) {
+	if !strings.Contains(value, `\`) {
+		return value, nil
+	}
+	var b strings.Builder
+	for len(value) > 0 {
+		// strconv.UnquoteChar only accepts escaped quotes matching the quote character
+		if strings.HasPrefix(value, `\"`) || strings.HasPrefix(value, `\'`) {
+			b.WriteByte(value[1])
+			value = value[2:]
+			continue
+		}
+		r, multibyte, tail, err := strconv.UnquoteChar(value, 0)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape sequence in %q", value)
+		}
+		if r < utf8.RuneSelf || !multibyte {
+			b.WriteByte(byte(r))
+		} else {
+			b.WriteRune(r)
+		}
+		value = tail
+	}
+	return b.String(), nil
+}
+
+// lookupSections returns the non-global Sections with the given name, or all of them if fqn is empty,
+// in declaration order. The caller must hold c.mutex.
+func (c *Configuration) lookupSections(fqn string) ([]*Section, error) {
+	var sections []*Section
+
+	f := func(lst *list.List) {
+		for e := lst.Front(); e != nil; e = e.Next() {
+			s := e.Value.(*Section)
+			sections = append(sections, s)
+		}
+	}
+
+	if fqn == "" {
+		// Get all sections.
+		for _, fqn := range c.orderedSections {
+			if lst, ok := c.sections[fqn]; ok {
+				f(lst)
+			}
+		}
+	} else {
+		fqn = c.opts.sectionName(fqn)
+		if lst, ok := c.sections[fqn]; ok {
+			f(lst)
+		} else if c.isGlobalName(fqn) {
+			sections = append(sections, c.global)
+		} else {
+			return nil, errors.New("Unable to find " + fqn)
+		}
+	}
+
+	return sections, nil
+}
+
 // addSection adds a new non-global section with the given name
 func (c *Configuration) addSection(fqn string) *Section {
-	section := newSection(fqn, false)
+	section := newSection(c.opts.sectionName(fqn), false, c.opts)
+	c.insertSection(section)
+	return section
+}
+
+// insertSection appends an existing non-global section, after any sections with the same name
+func (c *Configuration) insertSection(section *Section) {
+	if c.sections == nil {
+		c.sections = make(map[string]*list.List)
+	}
+	section.defaults = c.global
 
 	var lst *list.List
-	if lst = c.sections[fqn]; lst == nil {
+	if lst = c.sections[section.fqn]; lst == nil {
 		lst = list.New()
-		c.sections[fqn] = lst
-		c.orderedSections = append(c.orderedSections, fqn)
+		c.sections[section.fqn] = lst
+		c.orderedSections = append(c.orderedSections, section.fqn)
 	}
 
 	lst.PushBack(section)
+}
 
-	return section
+// init makes a zero value Section ready for use
+func (s *Section) init() {
+	if s.options == nil {
+		s.options = make(map[string]string)
+	}
+	if s.bare == nil {
+		s.bare = make(map[string]bool)
+	}
+}
+
+// copy returns a deep copy of the section
+func (s *Section) copy() *Section {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	c := newSection(s.fqn, s.isGlobal, s.opts)
+	c.header = s.header
+	c.condition = s.condition
+	for k, v := range s.options {
+		c.options[k] = v
+	}
+	for k, v := range s.bare {
+		c.bare[k] = v
+	}
+	c.meta = copyMeta(s.meta)
+	for opt, m := range s.optionMeta {
+		if c.optionMeta == nil {
+			c.optionMeta = make(map[string]map[string]string)
+		}
+		c.optionMeta[opt] = copyMeta(m)
+	}
+	c.orderedOptions = append([]string(nil), s.orderedOptions...)
+	c.rawLines = append([]string(nil), s.rawLines...)
+	c.firstLine = s.firstLine
+	c.file = s.file
+	for opt, n := range s.lines {
+		if c.lines == nil {
+			c.lines = make(map[string]int)
+		}
+		c.lines[opt] = n
+	}
+	return c
 }
@@ -0,0 +1,97 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package configtest provides helpers for testing code that reads or generates configurations.
+package configtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/grafana/configparser"
+)
+
+// S describes a section of a Configuration for Build.
+type S struct {
+	Name    string
+	Options []string // alternating option names and values
+}
+
+// Section describes a section with the given name, or the global section if name is empty,
+// and its options as alternating names and values, e.g. Section("server", "host", "localhost", "port", "80").
+func Section(name string, kv ...string) S {
+	if len(kv)%2 != 0 {
+		panic(fmt.Sprintf("configtest: odd number of option names and values for section %q", name))
+	}
+	return S{Name: name, Options: kv}
+}
+
+// Build returns a new Configuration with the given sections, in order.
+func Build(sections ...S) *configparser.Configuration {
+	conf := configparser.NewConfiguration()
+	for _, spec := range sections {
+		var s *configparser.Section
+		if spec.Name == "" {
+			s = conf.GlobalSection()
+		} else {
+			s = conf.NewSection(spec.Name)
+		}
+		for i := 0; i+1 < len(spec.Options); i += 2 {
+			s.Add(spec.Options[i], spec.Options[i+1])
+		}
+	}
+	return conf
+}
+
+// AssertEqual reports an error listing every difference between the sections and options of want and got,
+// comparing raw values in order, and including repeated sections.
+func AssertEqual(t testing.TB, want, got *configparser.Configuration) {
+	t.Helper()
+	if diff := Diff(want, got); len(diff) > 0 {
+		t.Errorf("configurations differ:\n%s", strings.Join(diff, "\n"))
+	}
+}
+
+// Diff returns a readable line for every difference between the sections and options of want and got.
+func Diff(want, got *configparser.Configuration) []string {
+	wantGlobal, wantSections, _ := want.AllSections()
+	gotGlobal, gotSections, _ := got.AllSections()
+
+	diff := diffSection("global section", wantGlobal, gotGlobal)
+	for i := 0; i < len(wantSections) || i < len(gotSections); i++ {
+		switch {
+		case i >= len(gotSections):
+			diff = append(diff, fmt.Sprintf("missing section [%s] (#%d)", wantSections[i].Name(), i+1))
+		case i >= len(wantSections):
+			diff = append(diff, fmt.Sprintf("unexpected section [%s] (#%d)", gotSections[i].Name(), i+1))
+		case wantSections[i].Name() != gotSections[i].Name():
+			diff = append(diff, fmt.Sprintf("section #%d: want [%s], got [%s]", i+1, wantSections[i].Name(), gotSections[i].Name()))
+		default:
+			name := fmt.Sprintf("section [%s] (#%d)", wantSections[i].Name(), i+1)
+			diff = append(diff, diffSection(name, wantSections[i], gotSections[i])...)
+		}
+	}
+	return diff
+}
+
+func diffSection(name string, want, got *configparser.Section) []string {
+	var diff []string
+	for _, opt := range want.OptionNames() {
+		if !got.Exists(opt) {
+			diff = append(diff, fmt.Sprintf("%s: missing option %q = %q", name, opt, want.RawValueOf(opt)))
+		} else if w, g := want.RawValueOf(opt), got.RawValueOf(opt); w != g {
+			diff = append(diff, fmt.Sprintf("%s: option %q: want %q, got %q", name, opt, w, g))
+		}
+	}
+	for _, opt := range got.OptionNames() {
+		if !want.Exists(opt) {
+			diff = append(diff, fmt.Sprintf("%s: unexpected option %q = %q", name, opt, got.RawValueOf(opt)))
+		}
+	}
+	if len(diff) == 0 && strings.Join(want.OptionNames(), "\n") != strings.Join(got.OptionNames(), "\n") {
+		diff = append(diff, fmt.Sprintf("%s: options in a different order: want %q, got %q", name, want.OptionNames(), got.OptionNames()))
+	}
+	return diff
+}
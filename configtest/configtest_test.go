@@ -0,0 +1,122 @@
+package configtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/grafana/configparser"
+)
+
+// recorder is a testing.TB that records errors instead of failing
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// run calls fn in a new goroutine, so Fatalf only stops fn, and waits for it to return
+func (r *recorder) run(fn func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	<-done
+}
+
+func TestAssertEqual(t *testing.T) {
+	in := `top = 1
+[server]
+host = localhost
+port = 80
+[server]
+port = 81
+`
+	got, err := configparser.Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Build(
+		Section("", "top", "1"),
+		Section("server", "host", "localhost", "port", "80"),
+		Section("server", "port", "81"),
+	)
+	AssertEqual(t, want, got)
+
+	want = Build(
+		Section("server", "port", "8080", "tls", "on"),
+		Section("client"),
+		Section("log"),
+	)
+	r := &recorder{TB: t}
+	AssertEqual(r, want, got)
+	expected := []string{
+		`global section: unexpected option "top" = "1"`,
+		`section [server] (#1): option "port": want "8080", got "80"`,
+		`section [server] (#1): missing option "tls" = "on"`,
+		`section [server] (#1): unexpected option "host" = "localhost"`,
+		`section #2: want [client], got [server]`,
+		`missing section [log] (#3)`,
+	}
+	if len(r.errors) != 1 {
+		t.Fatalf("expected one error, got %v", r.errors)
+	}
+	if diff := strings.Split(r.errors[0], "\n")[1:]; !reflect.DeepEqual(diff, expected) {
+		t.Fatalf("expected differences:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(diff, "\n"))
+	}
+}
+
+func TestDiffOrder(t *testing.T) {
+	want := Build(Section("server", "a", "1", "b", "2"))
+	got := Build(Section("server", "b", "2", "a", "1"))
+	diff := Diff(want, got)
+	if len(diff) != 1 || !strings.Contains(diff[0], "different order") {
+		t.Fatalf("expected an order difference, got %v", diff)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "testdata", "server.ini")
+
+	conf := Build(Section("server", "host", "localhost", "port", "80"))
+
+	r := &recorder{TB: t}
+	r.run(func() { Golden(r, path, conf) })
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "-update") {
+		t.Fatalf("expected an error suggesting -update for a missing golden file, got %v", r.errors)
+	}
+
+	*update = true
+	Golden(t, path, conf)
+	*update = false
+	Golden(t, path, conf)
+
+	conf.GlobalSection().Add("top", "1")
+	r = &recorder{TB: t}
+	Golden(r, path, conf)
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], `at line 1:`) {
+		t.Fatalf("expected a difference at line 1, got %v", r.errors)
+	}
+}
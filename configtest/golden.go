@@ -0,0 +1,64 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configtest
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grafana/configparser"
+)
+
+var update = flag.Bool("update", false, "update the golden files of configtest.Golden instead of comparing against them")
+
+// Golden writes conf with Write and compares the output to the golden file at path, usually under testdata,
+// reporting the first differing line. When the tests are run with -update, the golden file is written instead.
+func Golden(t testing.TB, path string, conf *configparser.Configuration) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := conf.Write(&buf); err != nil {
+		t.Fatalf("writing configuration: %s", err)
+	}
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file: %s (run with -update to create it)", err)
+	}
+	if bytes.Equal(want, buf.Bytes()) {
+		return
+	}
+
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(buf.String(), "\n")
+	for i := 0; ; i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g || i >= len(wantLines) || i >= len(gotLines) {
+			t.Errorf("output differs from golden file %s at line %d:\nwant %q\ngot  %q\n(run with -update to update it)", path, i+1, w, g)
+			return
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import "fmt"
+
+// metaConstant is the section and option metadata key marking constants, see MarkConstant
+const metaConstant = "constant"
+
+// MarkConstant makes the options constant: their values can no longer be changed or deleted with Add,
+// SetValueFor or Delete, which leave them as they are and log a warning, or with Set, which returns an error.
+// Constants are not changed by the transformers of WithTransformers, e.g. EnvTransformer, and layers merged
+// on top of the configuration, such as overlays and later files, may not override them, see
+// ReadFileWithOverlay and ReadFiles. This is meant for security-sensitive settings operators must not change.
+// Options can also be marked with OptionSchema.Constant, see WithSchema.
+func (s *Section) MarkConstant(options ...string) {
+	for _, option := range options {
+		s.SetOptionMeta(option, metaConstant, "true")
+	}
+}
+
+// MarkAllConstant makes all options of the section constant, see MarkConstant. Options can still be added
+// to the section.
+func (s *Section) MarkAllConstant() {
+	s.SetMeta(metaConstant, "true")
+}
+
+// IsConstant returns true if the option exists and is constant, see MarkConstant.
+func (s *Section) IsConstant(option string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.isConstant(s.opts.optionName(option))
+}
+
+// Set sets the value of the option like Add, but returns an error instead if the option is constant,
+// see MarkConstant.
+func (s *Section) Set(option, value string) error {
+	s.mutex.RLock()
+	constant := s.isConstant(s.opts.optionName(option))
+	s.mutex.RUnlock()
+	if constant {
+		return fmt.Errorf("option %s in section %s is constant", option, s.Name())
+	}
+	s.Add(option, value)
+	return nil
+}
+
+// isConstant returns true if the option, as returned by optionName, exists and is constant.
+// The caller must hold the lock.
+func (s *Section) isConstant(option string) bool {
+	if _, ok := s.options[option]; !ok {
+		return false
+	}
+	return s.meta[metaConstant] != "" || s.optionMeta[option][metaConstant] != ""
+}
+
+// keepConstant returns true, and logs a warning, if the option, as returned by optionName, is constant
+// and may not be changed. The caller must hold the lock.
+func (s *Section) keepConstant(option string) bool {
+	if !s.isConstant(option) {
+		return false
+	}
+	s.opts.log().Warn("ignoring change of constant option", "section", s.fqn, "option", option)
+	return true
+}
+
+// overrideViolation returns the violation of a layer read from file that sets the constant option of dst
+// to a different value in src
+func overrideViolation(dst, src *Section, option, file string) []Violation {
+	value := src.RawValueOf(option)
+	if !dst.IsConstant(option) || dst.RawValueOf(option) == value {
+		return nil
+	}
+	return []Violation{{
+		Section: src.Name(), Option: option, Value: value, Line: src.LineOf(option),
+		Message: fmt.Sprintf("is constant and may not be overridden by %s", file),
+	}}
+}
+
+// markConstants marks the options described as constant by the schema, see OptionSchema.Constant.
+// A nil schema is ignored.
+func markConstants(conf *Configuration, schema *Schema) {
+	if schema == nil {
+		return
+	}
+	for _, ss := range schema.Sections {
+		sections, err := ss.sections(conf)
+		if err != nil {
+			continue
+		}
+		for _, s := range sections {
+			if ss.Constant {
+				s.MarkAllConstant()
+			}
+			for _, opt := range ss.Options {
+				if opt.Constant {
+					s.MarkConstant(opt.Name)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package configparser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConstants(t *testing.T) {
+	logger := &recordingLogger{}
+	conf, err := Read(strings.NewReader("[security]\ntls = required\nkey = $HOME/key\nlevel = 1\n[locked]\na = 1\n"), "",
+		WithLogger(logger), WithTransformers(EnvTransformer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("security")
+	s.MarkConstant("tls", "key", "missing")
+	locked, _ := conf.Section("locked")
+	locked.MarkAllConstant()
+
+	if !s.IsConstant("tls") || s.IsConstant("level") || s.IsConstant("missing") || !locked.IsConstant("a") {
+		t.Fatal("unexpected constants")
+	}
+	if err := s.Set("tls", "off"); err == nil {
+		t.Fatal("expected error setting a constant")
+	}
+	if err := s.Set("level", "2"); err != nil || s.ValueOf("level") != "2" {
+		t.Fatalf("expected other options to be set, got %v", err)
+	}
+	s.Add("tls", "off")
+	s.SetValueFor("tls", "off")
+	s.Delete("tls")
+	locked.Add("a", "2")
+	if s.ValueOf("tls") != "required" || locked.ValueOf("a") != "1" || len(logger.warn) != 4 {
+		t.Fatalf("expected constants to be kept with warnings, got %q, %v", s.ValueOf("tls"), logger.warn)
+	}
+	locked.Add("b", "2")
+	if locked.ValueOf("b") != "2" {
+		t.Fatal("expected options to be added to a constant section")
+	}
+	if s.ValueOf("key") != "$HOME/key" {
+		t.Fatalf("expected constants not to be transformed, got %q", s.ValueOf("key"))
+	}
+	if err := conf.PromoteOption("security", "tls"); err == nil {
+		t.Fatal("expected error moving a constant")
+	}
+}
+
+func TestConstantsRenameAndReplace(t *testing.T) {
+	logger := &recordingLogger{}
+	read := func() (*Configuration, *Section) {
+		conf, err := Read(strings.NewReader(strings.NewReplacer(" = ", Delimiter).Replace("# security\ntls = required\nlevel = 1\n[other]\ntls = optional\n")), "", WithLogger(logger))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf.GlobalSection().MarkConstant("tls")
+		return conf, conf.GlobalSection()
+	}
+
+	conf, global := read()
+	if global.RenameOption("tls", "ssl") || global.RenameOption("level", "tls") || !global.IsConstant("tls") {
+		t.Fatal("expected constants not to be renamed or replaced")
+	}
+	if !global.RenameOption("level", "verbosity") {
+		t.Fatal("expected other options to be renamed")
+	}
+
+	n, err := conf.RenameOptionEverywhere("tls", "ssl")
+	if n != 1 || err == nil || err.Error() != "options not renamed: tls in [] to ssl: it is constant" {
+		t.Fatalf("expected 1 rename and the constant to be reported, got %d, %v", n, err)
+	}
+	if global.ValueOf("tls") != "required" || global.Exists("ssl") {
+		t.Fatal("expected the constant to be kept")
+	}
+	n, err = conf.RenameOptionsMatching("^(tls|verbosity)$", "x_$1")
+	if n != 1 || err == nil || err.Error() != "options not renamed: tls in [] to x_tls: it is constant" {
+		t.Fatalf("expected 1 rename and the constant to be reported, got %d, %v", n, err)
+	}
+
+	conf, global = read()
+	logger.warn = nil
+	global.Clear()
+	exp := "tls" + Delimiter + "required\n"
+	if conf.String() != exp+"[other]\ntls"+Delimiter+"optional\n" || !global.IsConstant("tls") || len(logger.warn) != 1 {
+		t.Fatalf("expected only the constant to be kept with a warning, got %q, %v", conf.String(), logger.warn)
+	}
+
+	conf, global = read()
+	logger.warn = nil
+	other, _ := conf.Section("other")
+	global.ReplaceWith(other)
+	if global.String() != exp || !global.IsConstant("tls") || len(logger.warn) != 1 {
+		t.Fatalf("expected the constant not to be overwritten, got %q, %v", global.String(), logger.warn)
+	}
+	other.Delete("tls")
+	other.Add("port", "80")
+	global.ReplaceWith(other)
+	if global.String() != "port"+Delimiter+"80\n"+exp || !global.IsConstant("tls") {
+		t.Fatalf("expected the constant to be kept after the new options, got %q", global.String())
+	}
+}
+
+func TestConstantsSchema(t *testing.T) {
+	schema := &Schema{
+		Sections: []SectionSchema{
+			{Name: "security", Options: []OptionSchema{{Name: "tls", Constant: true}, {Name: "level"}}},
+			{Name: "locked", Constant: true},
+		},
+	}
+	dir, err := ioutil.TempDir("", "configparser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	base := write("app.ini", "[security]\ntls = required\nlevel = 1\n[locked]\na = 1\n")
+	overlay := write("app.prod.ini", "[security]\ntls = required\nlevel = 2\n[locked]\na = 2\n")
+
+	conf, err := ReadFile(base, WithSchema(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := conf.Section("security"); !s.IsConstant("tls") || s.IsConstant("level") {
+		t.Fatal("expected tls to be constant")
+	}
+
+	_, err = ReadFileWithOverlay(base, "prod", WithSchema(schema))
+	exp := overlay + `:5: [locked] a = "2": is constant and may not be overridden by ` + overlay
+	if err == nil || err.Error() != exp {
+		t.Fatalf("expected error %q, got %v", exp, err)
+	}
+
+	write("app.prod.ini", "[security]\ntls = optional\n")
+	if _, err := ReadFileWithOverlay(base, "prod", WithSchema(schema)); err == nil {
+		t.Fatal("expected error overriding a constant")
+	}
+	write("app.prod.ini", "[locked]\nb = 1\n")
+	if _, err := ReadFileWithOverlay(base, "prod", WithSchema(schema)); err != nil {
+		t.Fatal(err)
+	}
+
+	globals := &Schema{Sections: []SectionSchema{{Name: "DEFAULT", Options: []OptionSchema{{Name: "mode", Constant: true}}}}}
+	first := write("10.conf", "mode = safe\n")
+	second := write("20.conf", "mode = unsafe\n")
+	if _, err := ReadFiles([]string{first, second}, WithSchema(globals), WithGlobalName("DEFAULT")); err == nil || !strings.HasPrefix(err.Error(), second+":1:") {
+		t.Fatalf("expected error for %s, got %v", second, err)
+	}
+}
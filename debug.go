@@ -0,0 +1,76 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// DebugDump writes a detailed description of the configuration to w, for attaching to bug reports about
+// how a file was parsed: every section, and every option with the line it was read from, its raw value
+// and its value without comments, where they differ, whether it is bare, and the file it came from if
+// not the configuration's own, see ReadFileWithOverlay. Comments and empty lines are listed as well.
+// Values are quoted, so whitespace and control characters are visible.
+func (c *Configuration) DebugDump(w io.Writer) error {
+	global, sections, err := c.AllSections()
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "file %q, %d sections\n", c.FilePath(), len(sections))
+	for _, s := range append([]*Section{global}, sections...) {
+		s.debugDump(bw)
+	}
+	return bw.Flush()
+}
+
+// debugDump writes the section to w, see DebugDump
+func (s *Section) debugDump(w io.Writer) {
+	switch {
+	case s.isGlobal && s.fqn != "":
+		fmt.Fprintf(w, "global section %q\n", s.fqn)
+	case s.isGlobal:
+		fmt.Fprintf(w, "global section\n")
+	case s.HeaderName() != s.Name():
+		fmt.Fprintf(w, "section %q, header %q\n", s.Name(), s.HeaderName())
+	default:
+		fmt.Fprintf(w, "section %q\n", s.Name())
+	}
+
+	for _, opt := range s.OptionNames() {
+		switch {
+		case opt == "":
+			fmt.Fprintf(w, "  empty line\n")
+			continue
+		case s.opts.isComment(opt) && s.State(opt) == StateBare:
+			fmt.Fprintf(w, "  comment %q\n", opt)
+			continue
+		case s.opts.isComment(opt):
+			// comments holding a delimiter are split like options
+			fmt.Fprintf(w, "  comment %q, value %q\n", opt, s.RawValueOf(opt))
+			continue
+		}
+
+		fmt.Fprintf(w, "  option %q", opt)
+		if line := s.LineOf(opt); line > 0 {
+			fmt.Fprintf(w, ", line %d", line)
+		}
+		if source := s.OptionMeta(opt, metaSource); source != "" {
+			fmt.Fprintf(w, ", from %q", source)
+		}
+		if s.State(opt) == StateBare {
+			fmt.Fprintf(w, ", bare\n")
+			continue
+		}
+		raw, value := s.RawValueOf(opt), s.ValueOfWithoutComments(opt)
+		fmt.Fprintf(w, "\n    raw   %q\n", raw)
+		if value != raw {
+			fmt.Fprintf(w, "    value %q\n", value)
+		}
+	}
+}
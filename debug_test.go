@@ -0,0 +1,41 @@
+package configparser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDebugDump(t *testing.T) {
+	in := "a = 1 # note\n\n[foo]\n# comment\n#x = y\nflag\nb =\nc = \"q\\t\"\n[foo]\n"
+	conf, err := Read(strings.NewReader(in), "app.ini", WithGlobalName("DEFAULT"), WithIndexedDuplicates())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("foo")
+	s.SetOptionMeta("b", metaSource, "app.prod.ini")
+
+	var buf bytes.Buffer
+	if err := conf.DebugDump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	exp := `file "app.ini", 2 sections
+global section "DEFAULT"
+  option "a", line 1
+    raw   "1 # note"
+    value "1"
+  empty line
+section "foo"
+  comment "# comment"
+  comment "#x", value "y"
+  option "flag", line 6, bare
+  option "b", line 7, from "app.prod.ini"
+    raw   ""
+  option "c", line 8
+    raw   "\"q\\t\""
+section "foo#2", header "foo"
+`
+	if buf.String() != exp {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, buf.String())
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+
+package configparser
+
+import "fmt"
+
+// DecodeSectionMap converts the value of every option of the section to T and returns them by option name,
+// for sections that are homogeneous tables such as [quotas]. T must be a string, bool, integer, float or
+// time.Duration, or a type based on one of them. Integers may be written in any base Go accepts, e.g. 0x10.
+// Comments and empty lines are skipped, and the first value that doesn't convert is returned as an error.
+func DecodeSectionMap[T any](s *Section) (map[string]T, error) {
+	m := make(map[string]T)
+	for _, opt := range s.OptionNames() {
+		if opt == "" || s.opts.isComment(opt) {
+			continue
+		}
+		var v T
+		if err := decodeValue(s.ValueOf(opt), &v); err != nil {
+			return nil, fmt.Errorf("section %q, option %q: %s", s.Name(), opt, err)
+		}
+		m[opt] = v
+	}
+	return m, nil
+}
@@ -0,0 +1,69 @@
+//go:build go1.18
+
+package configparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeSectionMap(t *testing.T) {
+	in := `[quotas]
+# per user
+alice = 10
+
+bob = 0x20
+[timeouts]
+read = 5s
+write = 1m
+[flags]
+debug = true
+[bad]
+a = 1
+
+b = x
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	section := func(name string) *Section {
+		s, err := conf.Section(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	quotas, err := DecodeSectionMap[int](section("quotas"))
+	if err != nil || !reflect.DeepEqual(quotas, map[string]int{"alice": 10, "bob": 32}) {
+		t.Fatalf("unexpected quotas %v, %v", quotas, err)
+	}
+	type quota uint8
+	if q, err := DecodeSectionMap[quota](section("quotas")); err != nil || q["bob"] != 32 {
+		t.Fatalf("unexpected quotas %v, %v", q, err)
+	}
+
+	timeouts, err := DecodeSectionMap[time.Duration](section("timeouts"))
+	if err != nil || !reflect.DeepEqual(timeouts, map[string]time.Duration{"read": 5 * time.Second, "write": time.Minute}) {
+		t.Fatalf("unexpected timeouts %v, %v", timeouts, err)
+	}
+	if flags, err := DecodeSectionMap[bool](section("flags")); err != nil || !flags["debug"] {
+		t.Fatalf("unexpected flags %v, %v", flags, err)
+	}
+	if strs, err := DecodeSectionMap[string](section("bad")); err != nil || !reflect.DeepEqual(strs, map[string]string{"a": "1", "b": "x"}) {
+		t.Fatalf("unexpected strings %v, %v", strs, err)
+	}
+
+	if _, err := DecodeSectionMap[int](section("bad")); err == nil || !strings.Contains(err.Error(), `option "b"`) {
+		t.Fatalf("expected error for option b, got %v", err)
+	}
+	if _, err := DecodeSectionMap[int8](section("timeouts")); err == nil {
+		t.Fatal("expected error for durations as int8")
+	}
+	if _, err := DecodeSectionMap[[]int](section("quotas")); err == nil {
+		t.Fatal("expected error for unsupported type")
+	}
+}
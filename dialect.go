@@ -0,0 +1,128 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Continuation controls how values continue on the following lines, see Dialect.
+type Continuation int
+
+const (
+	// ContinuationNone reads every line on its own.
+	ContinuationNone Continuation = iota
+	// ContinuationIndent continues the value of an option on the following indented lines, joined by
+	// newlines, like Python's configparser. Multi-line values are written with indented continuation lines.
+	ContinuationIndent
+	// ContinuationBackslash continues a line ending with a backslash on the next line, removing the
+	// backslash and the leading whitespace of the next line, like Java properties and git config.
+	ContinuationBackslash
+	// ContinuationBackslashSpace is like ContinuationBackslash, but replaces the backslash with a space,
+	// like systemd unit files.
+	ContinuationBackslashSpace
+)
+
+// A Dialect bundles the syntax rules of a configuration file format, see WithDialect.
+type Dialect struct {
+	// Delimiters are the characters separating option names from values; the first one in a line is used.
+	Delimiters string
+	// Comments are the characters that start a comment, see WithComments.
+	Comments     string
+	Continuation Continuation
+	// FoldSectionNames and FoldOptionNames make section and option names case-insensitive by lowercasing them.
+	FoldSectionNames bool
+	FoldOptionNames  bool
+}
+
+// Presets of common dialects, see WithDialect.
+var (
+	// ClassicINI is the classic Windows INI format, with case-insensitive names and ";" comments.
+	ClassicINI = Dialect{Delimiters: "=", Comments: ";", FoldSectionNames: true, FoldOptionNames: true}
+	// PythonConfigParser follows the defaults of Python's configparser.
+	PythonConfigParser = Dialect{Delimiters: "=:", Comments: "#;", Continuation: ContinuationIndent, FoldOptionNames: true}
+	// GitConfig follows git-config(1), apart from subsections, which are case-insensitive as well.
+	GitConfig = Dialect{Delimiters: "=", Comments: "#;", Continuation: ContinuationBackslash, FoldSectionNames: true, FoldOptionNames: true}
+	// SystemdUnit follows systemd.syntax(7).
+	SystemdUnit = Dialect{Delimiters: "=", Comments: "#;", Continuation: ContinuationBackslashSpace}
+	// JavaProperties follows java.util.Properties, apart from whitespace separating names from values.
+	JavaProperties = Dialect{Delimiters: "=:", Comments: "#!", Continuation: ContinuationBackslash}
+)
+
+// WithDialect sets the delimiters, comment characters, continuation lines and case rules of a dialect,
+// e.g. WithDialect(PythonConfigParser). Options given after it override the dialect's settings.
+// Values are written with Delimiter, which all presets accept.
+func WithDialect(d Dialect) Option {
+	return func(o *options) {
+		o.delimiters = d.Delimiters
+		o.comments = d.Comments
+		o.continuation = d.Continuation
+		o.foldSections = d.FoldSectionNames
+		o.foldOptions = d.FoldOptionNames
+	}
+}
+
+// delimiterIndex returns the index of the first delimiter in s, or -1 if there is none
+func (o *options) delimiterIndex(s string) int {
+	if o == nil || o.delimiters == "" {
+		return strings.Index(s, "=")
+	}
+	return strings.IndexAny(s, o.delimiters)
+}
+
+// backslashContinuation returns true if lines ending with a backslash continue on the next line
+func (o *options) backslashContinuation() bool {
+	return o != nil && (o.continuation == ContinuationBackslash || o.continuation == ContinuationBackslashSpace)
+}
+
+// continuedLines is a bufio.SplitFunc like bufio.ScanLines, but keeps lines ending with a backslash
+// together with the following line, separated by a newline
+func continuedLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	start := 0
+	for {
+		i := bytes.IndexByte(data[start:], '\n')
+		if i == -1 {
+			if atEOF && len(data) > 0 {
+				return len(data), bytes.TrimSuffix(data, []byte("\r")), nil
+			}
+			return 0, nil, nil
+		}
+		end := start + i
+		if !endsWithBackslash(string(data[start:end])) {
+			return end + 1, bytes.TrimSuffix(data[:end], []byte("\r")), nil
+		}
+		start = end + 1
+	}
+}
+
+// endsWithBackslash returns true if line, ignoring trailing whitespace, ends with an unescaped backslash
+func endsWithBackslash(line string) bool {
+	line = strings.TrimRight(line, " \t\r")
+	n := len(line) - len(strings.TrimRight(line, `\`))
+	return n%2 == 1
+}
+
+// joinContinued joins the lines of raw, as split by continuedLines, into a single line
+func (o *options) joinContinued(raw string) string {
+	lines := strings.Split(raw, "\n")
+	for i, line := range lines {
+		if i > 0 {
+			line = strings.TrimLeft(line, " \t")
+		}
+		if i < len(lines)-1 {
+			line = strings.TrimRight(line, " \t\r")
+			line = line[:len(line)-1]
+			if o.continuation == ContinuationBackslashSpace {
+				line = strings.TrimRight(line, " \t")
+			}
+		}
+		lines[i] = line
+	}
+	if o.continuation == ContinuationBackslashSpace {
+		return strings.Join(lines, " ")
+	}
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,96 @@
+package configparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDialects(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		in      string
+		section string
+		exp     map[string]string
+	}{
+		{
+			"python", PythonConfigParser,
+			"[Server]\nHost: localhost\n; comment\npaths = /a\n  /b\n\n  \nport=80\n",
+			"Server",
+			map[string]string{"host": "localhost", "paths": "/a\n/b", "port": "80"},
+		},
+		{
+			"git", GitConfig,
+			"[Core]\n\tEditor = vim\n\tpager = less \\\n\t  -R\n",
+			"core",
+			map[string]string{"editor": "vim", "pager": "less -R"},
+		},
+		{
+			"systemd", SystemdUnit,
+			"[Service]\nExecStart=/bin/app \\\n  --verbose \\\n  --port=80\nUser=app\n",
+			"Service",
+			map[string]string{"ExecStart": "/bin/app --verbose --port=80", "User": "app"},
+		},
+		{
+			"java", JavaProperties,
+			"! comment\nurl: http://host:80/\nlist = a,\\\n    b\nescaped = c:\\\\\nnext = d\n",
+			"",
+			map[string]string{"url": "http://host:80/", "list": "a,b", "escaped": `c:\\`, "next": "d"},
+		},
+		{
+			"classic", ClassicINI,
+			"[Boot Loader]\nTimeout=30\n; Default=off\n",
+			"boot loader",
+			map[string]string{"timeout": "30"},
+		},
+	}
+	for _, test := range tests {
+		conf, err := Read(strings.NewReader(test.in), "", WithDialect(test.dialect))
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		s := conf.GlobalSection()
+		if test.section != "" {
+			if s, err = conf.Section(test.section); err != nil {
+				t.Fatalf("%s: %s", test.name, err)
+			}
+		}
+		got := make(map[string]string)
+		for _, opt := range s.OptionNames() {
+			if opt != "" && !s.opts.isComment(opt) {
+				got[opt] = s.ValueOf(opt)
+			}
+		}
+		if len(got) != len(test.exp) {
+			t.Fatalf("%s: expected %q, got %q", test.name, test.exp, got)
+		}
+		for opt, value := range test.exp {
+			if got[opt] != value {
+				t.Fatalf("%s: expected %s = %q, got %q", test.name, opt, value, got[opt])
+			}
+		}
+	}
+}
+
+func TestDialectLineNumbers(t *testing.T) {
+	in := "[Service]\nExecStart=/bin/app \\\n  --verbose\nUser\n"
+	_, err := Read(strings.NewReader(in), "unit", WithDialect(SystemdUnit), WithStrictOptions())
+	if err == nil || !strings.Contains(err.Error(), "unit:4") {
+		t.Fatalf("expected error on line 4, got %v", err)
+	}
+}
+
+func TestDialectWriteContinuation(t *testing.T) {
+	in := "[paths]\nsearch = /a\n\t/b\n"
+	conf, err := Read(strings.NewReader(in), "", WithDialect(PythonConfigParser))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "[paths]\nsearch" + Delimiter + "/a\n\t/b\n"
+	if conf.String() != exp {
+		t.Fatalf("expected %q, got %q", exp, conf.String())
+	}
+	if err := RoundTrips([]byte(in), WithDialect(PythonConfigParser)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,6 +1,7 @@
 package configparser
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"strings"
@@ -203,3 +204,616 @@ func convertSections(ss []*Section) []receivedSection {
 	}
 	return out
 }
+
+func TestRawLines(t *testing.T) {
+	in := "a = 1\n\n  [foo] # comment here\n\tbar = baz  \n# a comment\n\n[qux]\n"
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	global, other, _ := conf.AllSections()
+
+	exp := [][]string{
+		{"a = 1", ""},
+		{"  [foo] # comment here", "\tbar = baz  ", "# a comment", ""},
+		{"[qux]"},
+	}
+	got := [][]string{global.RawLines()}
+	for _, s := range other {
+		got = append(got, s.RawLines())
+	}
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("raw lines mismatch\nexp %q\ngot %q", exp, got)
+	}
+
+	if lines := conf.NewSection("new").RawLines(); len(lines) != 0 {
+		t.Fatalf("expected no raw lines for a new section, got %q", lines)
+	}
+}
+
+func TestSectionWriteTo(t *testing.T) {
+	in := "[foo]   # comment here\n  bar   =   baz\n\n"
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("foo")
+
+	var canonical, raw bytes.Buffer
+	n, err := s.WriteTo(&canonical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "[foo]\nbar" + Delimiter + "baz\n\n"; canonical.String() != exp || n != int64(len(exp)) {
+		t.Fatalf("canonical form mismatch\nexp %q\ngot %q (%d bytes)", exp, canonical.String(), n)
+	}
+
+	if _, err := s.WriteRawTo(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw.String() != in {
+		t.Fatalf("preserved form mismatch\nexp %q\ngot %q", in, raw.String())
+	}
+
+	raw.Reset()
+	n2 := conf.NewSection("new")
+	n2.Add("a", "b")
+	n2.WriteRawTo(&raw)
+	if raw.String() != n2.String() {
+		t.Fatalf("expected canonical form for section without raw lines, got %q", raw.String())
+	}
+}
+
+func TestExtract(t *testing.T) {
+	in := `g = 1
+[a]
+# about a
+x = 1
+[b]
+y = 2
+[a]
+x = 3
+[c]
+z = 4
+`
+	conf, err := Read(strings.NewReader(in), "/tmp/configparser-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extracted := conf.Extract("c", "a", "missing")
+	exp := "[a]\n# about a\nx" + Delimiter + "1\n[a]\nx" + Delimiter + "3\n[c]\nz" + Delimiter + "4\n"
+	if got := extracted.String(); got != exp {
+		t.Fatalf("extracted config mismatch\nexp %q\ngot %q", exp, got)
+	}
+	if extracted.FilePath() != "" {
+		t.Fatalf("expected empty file path, got %q", extracted.FilePath())
+	}
+
+	// the extracted sections must be independent of the original ones
+	s, _ := extracted.Section("c")
+	s.SetValueFor("z", "5")
+	orig, _ := conf.Section("c")
+	if orig.ValueOf("z") != "4" {
+		t.Fatalf("modifying an extracted section changed the original")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	in := `g = 1
+[web.a]
+x = 1
+[db.a]
+y = 2
+[web.b]
+z = 3
+[other]
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := conf.Split(func(s *Section) string {
+		if s.Name() == "" {
+			return "global.conf"
+		}
+		if s.Name() == "other" {
+			return ""
+		}
+		return strings.SplitN(s.Name(), ".", 2)[0] + ".conf"
+	})
+
+	exp := map[string]string{
+		"global.conf": "g" + Delimiter + "1\n",
+		"web.conf":    "[web.a]\nx" + Delimiter + "1\n[web.b]\nz" + Delimiter + "3\n",
+		"db.conf":     "[db.a]\ny" + Delimiter + "2\n",
+	}
+	var names []string
+	for _, part := range parts {
+		names = append(names, part.FilePath())
+		if part.String() != exp[part.FilePath()] {
+			t.Fatalf("part %q mismatch\nexp %q\ngot %q", part.FilePath(), exp[part.FilePath()], part.String())
+		}
+	}
+	if !reflect.DeepEqual(names, []string{"global.conf", "web.conf", "db.conf"}) {
+		t.Fatalf("unexpected parts %q", names)
+	}
+}
+
+func TestWriteConcat(t *testing.T) {
+	a, err := Read(strings.NewReader("g = 1\n[a]\nx = 1\n"), "a.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Read(strings.NewReader("[b]\ny = 2\n"), "conf.d/b.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewConfiguration()
+	c.NewSection("c")
+
+	var buf bytes.Buffer
+	if err := WriteConcat(&buf, a, b, c); err != nil {
+		t.Fatal(err)
+	}
+	exp := "# --- from a.conf ---\ng" + Delimiter + "1\n[a]\nx" + Delimiter + "1\n" +
+		"# --- from conf.d/b.conf ---\n[b]\ny" + Delimiter + "2\n" +
+		"# --- from (unnamed) ---\n[c]\n"
+	if buf.String() != exp {
+		t.Fatalf("concatenated output mismatch\nexp %q\ngot %q", exp, buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteConcat(&buf, b, a); err == nil || buf.Len() != 0 {
+		t.Fatalf("expected an error for global options of a later configuration, got %v and %q", err, buf.String())
+	}
+}
+
+func TestUnescapedValueOf(t *testing.T) {
+	in := `[foo]
+plain = hello world
+newline = line one\nline two
+tab = a\tb
+unicode = caf\u00e9 \U0001F600
+hex = \x41\x42
+quotes = say \"hi\" and \'bye\'
+backslash = C:\\temp
+invalid = C:\temp\q
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("foo")
+
+	exp := map[string]string{
+		"plain":     "hello world",
+		"newline":   "line one\nline two",
+		"tab":       "a\tb",
+		"unicode":   "caf\u00e9 \U0001F600",
+		"hex":       "AB",
+		"quotes":    `say "hi" and 'bye'`,
+		"backslash": `C:\temp`,
+	}
+	for opt, want := range exp {
+		got, err := s.UnescapedValueOf(opt)
+		if err != nil {
+			t.Fatalf("option %q: unexpected error %s", opt, err)
+		}
+		if got != want {
+			t.Fatalf("option %q: expected %q, got %q", opt, want, got)
+		}
+	}
+	if s.ValueOf("newline") != `line one\nline two` {
+		t.Fatalf("expected the raw value to be preserved, got %q", s.ValueOf("newline"))
+	}
+	if _, err := s.UnescapedValueOf("invalid"); err == nil {
+		t.Fatal("expected error for invalid escape sequence")
+	}
+}
+
+func TestOptionState(t *testing.T) {
+	in := `[foo]
+empty =
+bare
+set = value
+readded
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("foo")
+	s.SetValueFor("readded", "")
+	s.Add("added", "")
+	s.Add("# comment", "")
+
+	exp := map[string]OptionState{
+		"empty":   StateEmpty,
+		"bare":    StateBare,
+		"set":     StateSet,
+		"readded": StateEmpty,
+		"added":   StateBare,
+		"missing": StateUnset,
+	}
+	for opt, want := range exp {
+		if got := s.State(opt); got != want {
+			t.Fatalf("option %q: expected state %d, got %d", opt, want, got)
+		}
+	}
+
+	delim := strings.TrimRight(Delimiter, " ")
+	expStr := "[foo]\nempty" + delim + "\nbare\nset" + Delimiter + "value\nreadded" + delim + "\nadded\n# comment\n"
+	if s.String() != expStr {
+		t.Fatalf("section string mismatch\nexp %q\ngot %q", expStr, s.String())
+	}
+}
+
+func TestMeta(t *testing.T) {
+	conf, err := Read(strings.NewReader("[foo]\na = 1\nb = 2\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("foo")
+	s.SetMeta("origin", "defaults.ini")
+	s.SetOptionMeta("a", "validated", "true")
+	s.SetOptionMeta("b", "hint", "a number")
+
+	if s.Meta("origin") != "defaults.ini" || s.OptionMeta("a", "validated") != "true" || s.OptionMeta("a", "hint") != "" {
+		t.Fatal("unexpected metadata")
+	}
+	if strings.Contains(conf.String(), "defaults.ini") {
+		t.Fatal("metadata must not be serialized")
+	}
+
+	extracted, _ := conf.Extract("foo").Section("foo")
+	s.SetOptionMeta("a", "validated", "false")
+	if extracted.Meta("origin") != "defaults.ini" || extracted.OptionMeta("a", "validated") != "true" {
+		t.Fatal("expected metadata to be copied along with the section")
+	}
+
+	s.Delete("b")
+	s.Add("b", "3")
+	if s.OptionMeta("b", "hint") != "" {
+		t.Fatal("expected metadata to be removed along with the option")
+	}
+}
+
+func TestOptionAt(t *testing.T) {
+	conf, err := Read(strings.NewReader("[foo]\nb = 2\n\na = 1\n# comment\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("foo")
+
+	var got [][2]string
+	for i := 0; i < s.NumOptions(); i++ {
+		opt, value, ok := s.OptionAt(i)
+		if key, _ := s.KeyAt(i); !ok || opt != key {
+			t.Fatalf("index %d: OptionAt and KeyAt disagree: %q vs %q", i, opt, key)
+		}
+		got = append(got, [2]string{opt, value})
+	}
+	exp := [][2]string{{"b", "2"}, {"", ""}, {"a", "1"}, {"# comment", ""}}
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("unexpected options\nexp %q\ngot %q", exp, got)
+	}
+
+	for _, i := range []int{-1, 4, 99} {
+		if _, _, ok := s.OptionAt(i); ok {
+			t.Fatalf("index %d: expected OptionAt to report an out of range index", i)
+		}
+		if _, ok := s.KeyAt(i); ok {
+			t.Fatalf("index %d: expected KeyAt to report an out of range index", i)
+		}
+	}
+}
+
+func TestNoPanics(t *testing.T) {
+	// deleting repeated options, such as empty lines, used to panic
+	conf, err := Read(strings.NewReader("[foo]\na = 1\n\n\nb = 2\n\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("foo")
+	s.Delete("")
+	if !reflect.DeepEqual(s.OptionNames(), []string{"a", "b"}) {
+		t.Fatalf("unexpected options after delete %q", s.OptionNames())
+	}
+
+	if _, err := conf.SetValue("missing", "a", "1"); err == nil {
+		t.Fatal("expected error setting a value in a missing section")
+	}
+	if old, err := conf.SetValue("foo", "a", "3"); err != nil || old != "1" || s.ValueOf("a") != "3" {
+		t.Fatalf("unexpected result setting a value: %q, %v", old, err)
+	}
+	if _, err := conf.DeleteOption("foo", "missing"); err == nil {
+		t.Fatal("expected error deleting a missing option")
+	}
+	if value, err := conf.DeleteOption("foo", "b"); err != nil || value != "2" || s.Exists("b") {
+		t.Fatalf("unexpected result deleting an option: %q, %v", value, err)
+	}
+
+	// zero values
+	var section Section
+	section.SetValueFor("a", "1")
+	section.Add("b", "2")
+	if section.ValueOf("b") != "2" || section.Delete("a") != "1" {
+		t.Fatal("unexpected values in zero value section")
+	}
+	var config Configuration
+	config.NewSection("foo").Add("a", "1")
+	if v, err := config.StringValue("foo", "a"); err != nil || v != "1" {
+		t.Fatalf("unexpected value in zero value configuration: %q, %v", v, err)
+	}
+}
+
+func TestCounts(t *testing.T) {
+	in := "g = 1\n[a]\nx = 1\n# comment\n[b]\n[a]\ny = 2\n"
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := conf.NumSections(); n != 3 {
+		t.Fatalf("expected 3 sections, got %d", n)
+	}
+	s, _ := conf.Section("a")
+	if n := s.NumOptions(); n != 2 {
+		t.Fatalf("expected 2 options, got %d", n)
+	}
+	if n := conf.GlobalSection().NumOptions(); n != 1 {
+		t.Fatalf("expected 1 global option, got %d", n)
+	}
+	if conf.Size() != len(conf.String()) {
+		t.Fatalf("expected size %d, got %d", len(conf.String()), conf.Size())
+	}
+	if n := NewConfiguration().NumSections(); n != 0 {
+		t.Fatalf("expected no sections, got %d", n)
+	}
+}
+
+func TestSub(t *testing.T) {
+	in := `[app]
+name = demo
+db.host = localhost
+db.port = 5432
+db.replica.host = replica
+dbx = 1
+cache.size = 10
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("app")
+
+	db := s.Sub("db")
+	if db.Name() != "app.db" {
+		t.Fatalf("unexpected name %q", db.Name())
+	}
+	if !reflect.DeepEqual(db.OptionNames(), []string{"host", "port", "replica.host"}) {
+		t.Fatalf("unexpected options %q", db.OptionNames())
+	}
+	if db.ValueOf("host") != "localhost" || db.Sub("replica").ValueOf("host") != "replica" {
+		t.Fatal("unexpected values")
+	}
+	if s.Sub("nope").NumOptions() != 0 {
+		t.Fatal("expected empty virtual section")
+	}
+
+	db.SetValueFor("host", "elsewhere")
+	if s.ValueOf("db.host") != "localhost" {
+		t.Fatal("changes to a virtual section must not affect the original")
+	}
+}
+
+func TestProfile(t *testing.T) {
+	in := `[db]
+host = localhost
+port = 5432
+[db:staging]
+host = db.staging
+[cache:staging]
+size = 10
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(section, option, exp string, expErr bool) {
+		t.Helper()
+		v, err := conf.StringValue(section, option)
+		if expErr != (err != nil) || v != exp {
+			t.Fatalf("profile %q, %s.%s: expected %q (error %t), got %q (%v)", conf.Profile(), section, option, exp, expErr, v, err)
+		}
+	}
+
+	check("db", "host", "localhost", false)
+	check("cache", "size", "", true)
+
+	conf.SetProfile("staging")
+	check("db", "host", "db.staging", false)
+	check("db", "port", "5432", false)
+	check("cache", "size", "10", false)
+
+	conf.SetProfile("prod")
+	check("db", "host", "localhost", false)
+}
+
+func TestStringCanonical(t *testing.T) {
+	in := `top=1
+[foo]
+a=1
+  b   =   2  
+c =
+d
+[bar]
+x  = y
+[foo]
+e= 5
+`
+	expected := `top = 1
+[foo]
+a = 1
+b = 2
+c =
+d
+[foo]
+e = 5
+[bar]
+x = y
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := conf.Section("bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Add(" z ", "  spaced  ")
+	expected = strings.Replace(expected, "x = y\n", "x = y\nz = spaced\n", 1)
+	expected = strings.NewReplacer(" = ", Delimiter, " =\n", strings.TrimRight(Delimiter, " ")+"\n").Replace(expected)
+
+	if conf.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, conf.String())
+	}
+	if conf.String() != conf.String() {
+		t.Fatal("expected String to be stable")
+	}
+
+	reread, err := Read(strings.NewReader(conf.String()), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reread.String() != expected {
+		t.Fatalf("expected the output to read back the same, got:\n%s", reread.String())
+	}
+}
+
+func TestTree(t *testing.T) {
+	in := `[foo]
+# comment
+
+name = x
+limits.cpu = 2
+limits.mem = 1G
+limits = default
+net.ports.http = 80
+net.ports.https = 443
+net = on
+
+net.ports.http = 8080
+name.first = y
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("foo")
+	exp := map[string]interface{}{
+		"name": map[string]interface{}{
+			"":      "x",
+			"first": "y",
+		},
+		"limits": map[string]interface{}{
+			"":    "default",
+			"cpu": "2",
+			"mem": "1G",
+		},
+		"net": map[string]interface{}{
+			"": "on",
+			"ports": map[string]interface{}{
+				"http":  "8080",
+				"https": "443",
+			},
+		},
+	}
+	if tree := s.Tree(); !reflect.DeepEqual(tree, exp) {
+		t.Fatalf("expected %v, got %v", exp, tree)
+	}
+}
+
+func TestClearAndReplaceGlobal(t *testing.T) {
+	conf, err := Read(strings.NewReader("# top\na = 1\n[foo]\nb = 2\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Read(strings.NewReader("x = 9\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	global := conf.GlobalSection()
+	global.ReplaceWith(other.GlobalSection())
+	other.GlobalSection().Add("y", "10")
+	if exp := "x" + Delimiter + "9\n[foo]\nb" + Delimiter + "2\n"; conf.String() != exp {
+		t.Fatalf("expected %q, got %q", exp, conf.String())
+	}
+
+	global.Clear()
+	if global.NumOptions() != 0 || global.Exists("x") || global.LineOf("x") != 0 {
+		t.Fatalf("expected no global options, got %v", global.OptionNames())
+	}
+	if exp := "[foo]\nb" + Delimiter + "2\n"; conf.String() != exp {
+		t.Fatalf("expected %q, got %q", exp, conf.String())
+	}
+}
+
+func TestPromoteDemoteOption(t *testing.T) {
+	conf, err := Read(strings.NewReader("a = 1\nflag\nempty =\n[foo]\nb = 2\na = 0\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.GlobalSection().SetOptionMeta("a", "k", "v")
+
+	if err := conf.DemoteOption("a", "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.DemoteOption("flag", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.DemoteOption("empty", "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.PromoteOption("foo", "b"); err != nil {
+		t.Fatal(err)
+	}
+	exp := "b" + Delimiter + "2\n[foo]\na" + Delimiter + "1\nempty" + strings.TrimRight(Delimiter, " ") + "\n[bar]\nflag\n"
+	if conf.String() != exp {
+		t.Fatalf("expected %q, got %q", exp, conf.String())
+	}
+	if s, _ := conf.Section("foo"); s.OptionMeta("a", "k") != "v" {
+		t.Fatal("expected metadata to be moved along")
+	}
+
+	if err := conf.DemoteOption("missing", "foo"); err == nil {
+		t.Fatal("expected error for missing option")
+	}
+	if err := conf.PromoteOption("missing", "a"); err == nil {
+		t.Fatal("expected error for missing section")
+	}
+	if err := conf.PromoteOption("foo", "missing"); err == nil {
+		t.Fatal("expected error for missing option")
+	}
+}
+
+func TestLargestValues(t *testing.T) {
+	conf, err := Read(strings.NewReader("a = 123\n[foo]\n# a long comment is not a value\nb = 12345\nc = 1\n[bar]\nd = 123\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []ValueSize{
+		{Section: "foo", Option: "b", Size: 5, Line: 4},
+		{Section: "", Option: "a", Size: 3, Line: 1},
+		{Section: "bar", Option: "d", Size: 3, Line: 7},
+	}
+	if got := conf.LargestValues(3); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+	if got := conf.LargestValues(-1); len(got) != 4 {
+		t.Fatalf("expected all values, got %v", got)
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+// ReadFiles reads several files into one Configuration, e.g. the files of a conf.d directory, see ReadDir.
+// Sections are added in the order of the files, after any sections with the same name read earlier, and
+// remember the file they were read from, see Section.File. Global options of later files are added to the
+// global section, replacing the same options of earlier files, and record their file as their source,
+// see OptionMeta, unless they are constant, see MarkConstant: a *ValidationError lists the global options of
+// later files that set constants to different values. The file path of the Configuration is that of the first file.
+// WithSchema validates the merged result rather than each file, so Checks see the sections of all files, and
+// violations name the file the option was read from, see Violation.File.
+// SaveFiles writes the sections and global options back to the files they were read from, and Reload reads
+// all the files again.
+func ReadFiles(filePaths []string, opts ...Option) (*Configuration, error) {
+	if len(filePaths) == 0 {
+		return nil, errors.New("no files to read")
+	}
+	filePaths, opts = append([]string(nil), filePaths...), append([]Option(nil), opts...)
+	p, schema := layerParser(opts)
+	c, err := p.ReadFile(filePaths[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, filePath := range filePaths[1:] {
+		next, err := p.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		markConstants(c, schema)
+		if violations := c.add(next); len(violations) > 0 {
+			return nil, &ValidationError{FilePath: filePath, Violations: violations}
+		}
+	}
+
+	if err := applySchema(c, schema); err != nil {
+		return nil, err
+	}
+	c.reread = func() (*Configuration, error) { return ReadFiles(filePaths, opts...) }
+	return c, nil
+}
+
+// ReadDir reads the files in dir whose names match the pattern, e.g. "*.conf", in lexical order,
+// see ReadFiles and filepath.Match. An error is returned if no files match.
+func ReadDir(dir, pattern string, opts ...Option) (*Configuration, error) {
+	filePaths, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return nil, err
+	}
+	if len(filePaths) == 0 {
+		return nil, errors.New("no files matching " + filepath.Join(dir, pattern))
+	}
+	return ReadFiles(filePaths, opts...)
+}
+
+// SaveFiles saves a Configuration read with ReadFiles back to its files with Save: each section to the file it
+// was read from, see Section.File, and each global option to the file that set it. Sections and global options
+// that were added later go to the file path of the Configuration. Global options that a later file replaced
+// are saved to the earlier file as they were read, with the comments right before them.
+func SaveFiles(c *Configuration, opts ...SaveOption) error {
+	for _, part := range c.splitFiles() {
+		if err := Save(part, part.FilePath(), opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add adds the sections and global options of next, read from another file, see ReadFiles.
+// Global options that would override constants are left out and returned as violations.
+func (c *Configuration) add(next *Configuration) (violations []Violation) {
+	global, sections, _ := next.AllSections()
+	for _, opt := range global.OptionNames() {
+		isOption := opt != "" && !c.opts.isComment(opt)
+		if isOption && c.global.IsConstant(opt) {
+			violations = append(violations, overrideViolation(c.global, global, opt, next.FilePath())...)
+			continue
+		}
+		if isOption && c.global.Exists(opt) {
+			shadowed := c.shadow(opt)
+			c.mutex.Lock()
+			c.shadowed = append(c.shadowed, shadowed)
+			c.mutex.Unlock()
+			c.global.Delete(opt)
+		}
+		c.global.mutex.Lock()
+		c.global.init()
+		c.global.orderedOptions = append(c.global.orderedOptions, opt)
+		c.global.options[opt] = global.options[opt]
+		if global.bare[opt] {
+			c.global.bare[opt] = true
+		} else {
+			delete(c.global.bare, opt)
+		}
+		if n, ok := global.lines[opt]; ok && isOption {
+			if c.global.lines == nil {
+				c.global.lines = make(map[string]int)
+			}
+			c.global.lines[opt] = n
+		}
+		c.global.mutex.Unlock()
+		if isOption {
+			c.global.SetOptionMeta(opt, metaSource, next.FilePath())
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, s := range sections {
+		c.insertSection(s)
+	}
+	next.mutex.RLock()
+	c.excluded = append(c.excluded, next.excluded...)
+	next.mutex.RUnlock()
+	return violations
+}
+
+// shadowedOption is a global option of a file that a later file replaced, see ReadFiles, which SaveFiles
+// saves back to its file
+type shadowedOption struct {
+	file    string
+	index   int           // the position of the first entry among the global options saved to the file
+	entries []globalEntry // the comments right before the option, and the option
+}
+
+// globalEntry is an option, comment or empty line of a global section
+type globalEntry struct {
+	name, value string
+	bare        bool
+}
+
+// shadow removes the global option, which a later file replaces, see add, and the comments right before it
+// from the global section, and returns them
+func (c *Configuration) shadow(option string) shadowedOption {
+	g := c.global
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	option = g.opts.optionName(option)
+	files := c.globalFiles(g)
+	pos := 0
+	for pos < len(g.orderedOptions) && g.orderedOptions[pos] != option {
+		pos++
+	}
+	start := pos
+	for start > 0 && g.orderedOptions[start-1] != "" && c.opts.isComment(g.orderedOptions[start-1]) {
+		start--
+	}
+
+	shadowed := shadowedOption{file: files[pos]}
+	for _, file := range files[:start] {
+		if file == shadowed.file {
+			shadowed.index++
+		}
+	}
+	for _, name := range g.orderedOptions[start : pos+1] {
+		shadowed.entries = append(shadowed.entries, globalEntry{name: name, value: g.options[name], bare: g.bare[name]})
+	}
+	// the option itself is deleted by the caller
+	g.orderedOptions = append(g.orderedOptions[:start], g.orderedOptions[pos:]...)
+	return shadowed
+}
+
+// globalFiles returns the file each entry of the global section is saved to, see SaveFiles.
+// The caller must hold the lock of global.
+func (c *Configuration) globalFiles(global *Section) []string {
+	// comments and empty lines are stored under the same names for all files, so comments go with the
+	// option that follows them, and empty lines, as well as comments at the end, with the one before them
+	isOption := func(opt string) bool { return opt != "" && !c.opts.isComment(opt) }
+	files := make([]string, len(global.orderedOptions))
+	next := ""
+	for i := len(files) - 1; i >= 0; i-- {
+		switch opt := global.orderedOptions[i]; {
+		case isOption(opt):
+			next = c.fileOf(global, opt)
+			files[i] = next
+		case opt != "":
+			files[i] = next
+		}
+	}
+	previous := c.FilePath()
+	for i, opt := range global.orderedOptions {
+		if isOption(opt) {
+			previous = files[i]
+		} else if files[i] == "" {
+			files[i] = previous
+		}
+	}
+	return files
+}
+
+// splitFiles partitions c into Configurations holding the sections and global options of each file, see SaveFiles
+func (c *Configuration) splitFiles() []*Configuration {
+	global, sections, _ := c.AllSections()
+
+	var parts []*Configuration
+	byFile := make(map[string]*Configuration)
+	part := func(file string) *Configuration {
+		if file == "" {
+			file = c.FilePath()
+		}
+		if p, ok := byFile[file]; ok {
+			return p
+		}
+		p := newConfiguration(file, c.opts)
+		byFile[file] = p
+		parts = append(parts, p)
+		return p
+	}
+	part(c.FilePath())
+
+	global.mutex.RLock()
+	for i, opt := range c.globalFiles(global) {
+		g := part(opt).global
+		name := global.orderedOptions[i]
+		g.orderedOptions = append(g.orderedOptions, name)
+		g.options[name] = global.options[name]
+		if global.bare[name] {
+			g.bare[name] = true
+		}
+	}
+	global.mutex.RUnlock()
+
+	// options replaced by later files are put back in reverse, as each was removed after the ones before it
+	c.mutex.RLock()
+	shadowed := c.shadowed
+	c.mutex.RUnlock()
+	for i := len(shadowed) - 1; i >= 0; i-- {
+		sh := shadowed[i]
+		g := part(sh.file).global
+		index := sh.index
+		if index > len(g.orderedOptions) {
+			index = len(g.orderedOptions)
+		}
+		names := make([]string, 0, len(g.orderedOptions)+len(sh.entries))
+		names = append(names, g.orderedOptions[:index]...)
+		for _, e := range sh.entries {
+			names = append(names, e.name)
+			g.options[e.name] = e.value
+			if e.bare {
+				g.bare[e.name] = true
+			}
+		}
+		g.orderedOptions = append(names, g.orderedOptions[index:]...)
+	}
+
+	copies := make(map[*Section]*Section)
+	for _, s := range sections {
+		p := part(s.File())
+		copies[s] = s.copy()
+		p.mutex.Lock()
+		p.insertSection(copies[s])
+		p.mutex.Unlock()
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	for _, e := range c.excluded {
+		// sections that followed a section of another file were the first of their file
+		p := part(e.section.File())
+		after := copies[e.after]
+		if after != nil && after.File() != e.section.File() {
+			after = nil
+		}
+		p.excluded = append(p.excluded, excludedSection{after: after, section: e.section.copy()})
+	}
+	return parts
+}
+
+// fileOf returns the file the global option was read from
+func (c *Configuration) fileOf(global *Section, opt string) string {
+	if file := global.optionMeta[opt][metaSource]; file != "" {
+		return file
+	}
+	return c.FilePath()
+}
@@ -0,0 +1,173 @@
+package configparser
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadDirSaveFiles(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(strings.Replace(content, " = ", Delimiter, -1)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	base := write("10-base.conf", "# base settings\nname = app\nlevel = info\n\n[server]\nport = 80\n")
+	extra := write("20-extra.conf", "# extra settings\nlevel = debug\n[server]\nport = 81\n[cache]\nsize = 1\n")
+	write("README", "not a configuration")
+
+	conf, err := ReadDir(dir, "*.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.FilePath() != base {
+		t.Fatalf("expected the path of the first file, got %q", conf.FilePath())
+	}
+	if v := conf.GlobalSection().ValueOf("level"); v != "debug" {
+		t.Fatalf("expected global options of later files to win, got %q", v)
+	}
+	servers, _ := conf.Sections("server")
+	if len(servers) != 2 || servers[0].File() != base || servers[1].File() != extra {
+		t.Fatalf("expected a [server] section from each file, got %d", len(servers))
+	}
+
+	servers[1].Add("port", "8081")
+	cache, _ := conf.Section("cache")
+	cache.Add("size", "2")
+	conf.NewSection("new").Add("a", "1")
+	if err := SaveFiles(conf); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := strings.Replace("# base settings\nname = app\nlevel = info\n\n[server]\nport = 80\n[new]\na = 1\n", " = ", Delimiter, -1)
+	if got := readString(t, base); got != exp {
+		t.Fatalf("expected %s:\n%s\ngot:\n%s", base, exp, got)
+	}
+	exp = strings.Replace("# extra settings\nlevel = debug\n[server]\nport = 8081\n[cache]\nsize = 2\n", " = ", Delimiter, -1)
+	if got := readString(t, extra); got != exp {
+		t.Fatalf("expected %s:\n%s\ngot:\n%s", extra, exp, got)
+	}
+
+	// Reload reads all the files again
+	write("20-extra.conf", "[cache]\nsize = 2\n")
+	if err := conf.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := conf.StringValue("cache", "size"); v != "2" || conf.GlobalSection().ValueOf("name") != "app" {
+		t.Fatalf("expected all files to be read again, got %q", conf.String())
+	}
+
+	if _, err := ReadDir(dir, "*.ini"); err == nil {
+		t.Fatal("expected error for no matching files")
+	}
+	if _, err := ReadFiles(nil); err == nil {
+		t.Fatal("expected error for no files")
+	}
+}
+
+func TestSaveFilesReplacedGlobals(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	files := map[string]string{
+		"10.conf": "# first\na = 1\n# about b\nb = 1\nc = 1\n\n[s]\nx = 1\n",
+		"20.conf": "b = 2\n# about a\na = 2\n",
+		"30.conf": "b = 3\n",
+	}
+	for name, content := range files {
+		files[name] = strings.Replace(content, " = ", Delimiter, -1)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(files[name]), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf, err := ReadDir(dir, "*.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	global := conf.GlobalSection()
+	if global.ValueOf("a") != "2" || global.ValueOf("b") != "3" || global.LineOf("a") != 3 || global.LineOf("b") != 1 {
+		t.Fatalf("expected the values and lines of the last files, got %q", global.Options())
+	}
+	if err := SaveFiles(conf); err != nil {
+		t.Fatal(err)
+	}
+	for name, exp := range files {
+		if got := readString(t, filepath.Join(dir, name)); got != exp {
+			t.Fatalf("expected %s to be saved unchanged:\n%q\ngot:\n%q", name, exp, got)
+		}
+	}
+}
+
+func TestSaveFilesExcluded(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(strings.Replace(content, " = ", Delimiter, -1)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	base := write("10-base.conf", "[a]\nx = 1\n[win] @if os=windows\nx = 2\n")
+	extra := write("20-extra.conf", "[mac] @if os=darwin\nx = 3\n[b]\nx = 4\n")
+
+	conf, err := ReadDir(dir, "*.conf", WithVariables(map[string]string{"os": "linux"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.NumSections() != 2 || len(conf.ExcludedSections()) != 2 {
+		t.Fatalf("expected 2 sections and 2 excluded sections, got %d and %d", conf.NumSections(), len(conf.ExcludedSections()))
+	}
+	if err := SaveFiles(conf); err != nil {
+		t.Fatal(err)
+	}
+	for path, exp := range map[string]string{base: "[a]\nx = 1\n[win] @if os=windows\nx = 2\n", extra: "[mac] @if os=darwin\nx = 3\n[b]\nx = 4\n"} {
+		if exp = strings.Replace(exp, " = ", Delimiter, -1); readString(t, path) != exp {
+			t.Fatalf("expected %s:\n%s\ngot:\n%s", path, exp, readString(t, path))
+		}
+	}
+}
+
+func TestReadFilesSchema(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(strings.Replace(content, " = ", Delimiter, -1)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	routes := func(conf *Configuration) []Violation {
+		sections, _ := conf.Find("^route-")
+		var violations []Violation
+		for _, s := range sections {
+			if _, err := conf.Section("destination-" + s.ValueOf("to")); err != nil {
+				violations = append(violations, Violation{Section: s.Name(), Option: "to", Value: s.ValueOf("to"), Message: "no such destination"})
+			}
+		}
+		return violations
+	}
+	schema := &Schema{
+		Sections: []SectionSchema{{Name: "route-1", Options: []OptionSchema{{Name: "to", Pattern: "[a-z]"}}}},
+		Checks:   []Check{routes},
+	}
+	write("10-dest.conf", "[destination-a]\n")
+	route := write("20-route.conf", "# routes\n[route-1]\nto = a\n")
+
+	if _, err := ReadDir(dir, "*.conf", WithSchema(schema)); err != nil {
+		t.Fatalf("expected the check to see the sections of all files, got %v", err)
+	}
+
+	write("20-route.conf", "# routes\n[route-1]\nto = A\n")
+	_, err := ReadDir(dir, "*.conf", WithSchema(schema))
+	exp := route + `:3: [route-1] to = "A": must match [a-z]; ` + filepath.Join(dir, "10-dest.conf") + `: [route-1] to = "A": no such destination`
+	if err == nil || err.Error() != exp {
+		t.Fatalf("expected error %q, got %v", exp, err)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A GrepMatch is a line matched by Grep.
+type GrepMatch struct {
+	Section string // the name of the section holding the line, "" for the global section
+	Line    int
+	Text    string // the line without leading and trailing whitespace
+}
+
+// Grep returns the source lines matching the regexp, in file order: section headers, options and their
+// values, as well as comments, e.g. to find references in commented-out examples. Only the lines as they
+// were read are searched, see RawLines, so changes made after reading are not reflected.
+func (c *Configuration) Grep(regex string) ([]GrepMatch, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+	global, sections, err := c.AllSections()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []GrepMatch
+	for _, s := range append([]*Section{global}, sections...) {
+		s.mutex.RLock()
+		line := s.firstLine
+		for _, raw := range s.rawLines {
+			// lines continued with a backslash are kept together, see Continuation
+			for _, text := range strings.Split(raw, "\n") {
+				if re.MatchString(text) {
+					matches = append(matches, GrepMatch{Section: s.fqn, Line: line, Text: strings.TrimSpace(text)})
+				}
+				line++
+			}
+		}
+		s.mutex.RUnlock()
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Line < matches[j].Line })
+	return matches, nil
+}
@@ -0,0 +1,57 @@
+package configparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGrep(t *testing.T) {
+	in := `# upstream = backup.local
+[proxy]
+upstream = primary.local
+# timeout = 5
+[cache]
+path = /var/cache/upstream
+[proxy]
+; upstream = disabled
+`
+	conf, err := Read(strings.NewReader(in), "", WithComments('#', ';'))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.GlobalSection().Add("upstream_added", "later")
+
+	matches, err := conf.Grep("upstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []GrepMatch{
+		{Section: "", Line: 1, Text: "# upstream = backup.local"},
+		{Section: "proxy", Line: 3, Text: "upstream = primary.local"},
+		{Section: "cache", Line: 6, Text: "path = /var/cache/upstream"},
+		{Section: "proxy", Line: 8, Text: "; upstream = disabled"},
+	}
+	if !reflect.DeepEqual(matches, exp) {
+		t.Fatalf("expected %v, got %v", exp, matches)
+	}
+
+	if matches, _ := conf.Grep(`^\[cache\]$`); len(matches) != 1 || matches[0].Line != 5 {
+		t.Fatalf("expected the header on line 5, got %v", matches)
+	}
+	if _, err := conf.Grep("("); err == nil {
+		t.Fatal("expected error for invalid regexp")
+	}
+}
+
+func TestGrepContinuation(t *testing.T) {
+	in := "[Service]\nExecStart=/bin/app \\\n  --verbose\nUser=app\n"
+	conf, err := Read(strings.NewReader(in), "", WithDialect(SystemdUnit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, _ := conf.Grep("verbose|User")
+	if len(matches) != 2 || matches[0].Line != 3 || matches[1].Line != 4 {
+		t.Fatalf("expected matches on lines 3 and 4, got %v", matches)
+	}
+}
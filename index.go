@@ -0,0 +1,77 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import "strings"
+
+// An Index is an immutable snapshot of the values of a Configuration, for services that look up many values
+// from a configuration that doesn't change, see Configuration.Index. It can be used concurrently without locking.
+type Index struct {
+	values map[indexKey]string
+	opts   *options
+}
+
+type indexKey struct {
+	section, option string
+}
+
+// Index returns an Index of the values of all options, as returned by StringValue at the time of the call:
+// values are interpolated and transformed, the first of repeated sections is used, and values of the
+// active profile take precedence, see SetProfile. The global section is indexed under the name "" and,
+// if set, the name of WithGlobalName. Later changes to the Configuration are not reflected.
+func (c *Configuration) Index() *Index {
+	sections, _ := c.Sections("")
+	profile := c.Profile()
+
+	ix := &Index{values: make(map[indexKey]string), opts: c.opts}
+	add := func(name string, s *Section) {
+		for _, opt := range s.OptionNames() {
+			if opt != "" && !s.opts.isComment(opt) {
+				ix.values[indexKey{name, opt}] = s.ValueOf(opt)
+			}
+		}
+	}
+
+	if c.global != nil {
+		add("", c.global)
+		if name := c.GlobalName(); name != "" {
+			add(name, c.global)
+		}
+	}
+	first := make(map[string]*Section)
+	for _, s := range sections {
+		if _, ok := first[s.Name()]; !ok {
+			first[s.Name()] = s
+			add(s.Name(), s)
+		}
+	}
+	if profile != "" {
+		suffix := ProfileSeparator + profile
+		for name, s := range first {
+			if strings.HasSuffix(name, suffix) && name != suffix {
+				add(strings.TrimSuffix(name, suffix), s)
+			}
+		}
+	}
+	return ix
+}
+
+// Lookup returns the value of the option in the section, and whether it exists.
+// Use the section name "" for the global section.
+func (ix *Index) Lookup(section, option string) (value string, ok bool) {
+	value, ok = ix.values[indexKey{ix.opts.sectionName(section), ix.opts.optionName(option)}]
+	return
+}
+
+// Value returns the value of the option in the section, or "" if it doesn't exist, see Lookup.
+func (ix *Index) Value(section, option string) string {
+	value, _ := ix.Lookup(section, option)
+	return value
+}
+
+// Len returns the number of indexed options.
+func (ix *Index) Len() int {
+	return len(ix.values)
+}
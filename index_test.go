@@ -0,0 +1,84 @@
+package configparser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	in := `top = 1
+# comment
+[db]
+host = localhost
+port = 5432
+[db:staging]
+host = staging.local
+[db]
+host = other
+user = admin
+`
+	conf, err := Read(strings.NewReader(in), "", WithGlobalName("DEFAULT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.SetProfile("staging")
+	ix := conf.Index()
+	conf.SetProfile("")
+	s, _ := conf.Section("db")
+	s.SetValueFor("port", "1")
+
+	tests := []struct {
+		section, option, exp string
+		ok                   bool
+	}{
+		{"", "top", "1", true},
+		{"DEFAULT", "top", "1", true},
+		{"db", "host", "staging.local", true},
+		{"db", "port", "5432", true},
+		{"db", "user", "", false},
+		{"db:staging", "host", "staging.local", true},
+		{"", "# comment", "", false},
+		{"missing", "host", "", false},
+	}
+	for _, test := range tests {
+		value, ok := ix.Lookup(test.section, test.option)
+		if value != test.exp || ok != test.ok {
+			t.Fatalf("[%s] %s: expected %q %t, got %q %t", test.section, test.option, test.exp, test.ok, value, ok)
+		}
+	}
+	if ix.Len() != 5 {
+		t.Fatalf("expected 5 indexed options, got %d", ix.Len())
+	}
+}
+
+func BenchmarkIndex(b *testing.B) {
+	var sb strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&sb, "[section%d]\n", i)
+		for j := 0; j < 20; j++ {
+			fmt.Fprintf(&sb, "option%d = value%d\n", j, j)
+		}
+	}
+	conf, err := Read(strings.NewReader(sb.String()), "")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("StringValue", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := conf.StringValue("section50", "option10"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Index", func(b *testing.B) {
+		ix := conf.Index()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, ok := ix.Lookup("section50", "option10"); !ok {
+				b.Fatal("missing option")
+			}
+		}
+	})
+}
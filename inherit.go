@@ -0,0 +1,89 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExtendsOption is the option naming the section a section inherits its options from, see Flattened.
+const ExtendsOption = "extends"
+
+// Flattened returns a virtual section holding the options of the first section with the given name and of the
+// sections it extends: a section with "extends = base" inherits all options of [base] that it doesn't set
+// itself, and base may extend another section in turn. Inherited options come first, in the order of their section.
+// The returned section is a detached copy without comments and without the extends option; changes to it
+// don't affect the configuration.
+// An error is returned if a section doesn't exist, or if sections extend each other in a cycle.
+func (c *Configuration) Flattened(name string) (*Section, error) {
+	chain, err := c.inheritance(name)
+	if err != nil {
+		return nil, err
+	}
+
+	flat := newSection(chain[0].Name(), false, c.opts)
+	flat.defaults = c.global
+	extends := c.opts.optionName(ExtendsOption)
+	for i := len(chain) - 1; i >= 0; i-- {
+		s := chain[i]
+		s.mutex.RLock()
+		for _, opt := range s.orderedOptions {
+			if opt == "" || opt == extends || s.opts.isComment(opt) {
+				continue
+			}
+			if _, ok := flat.options[opt]; !ok {
+				flat.orderedOptions = append(flat.orderedOptions, opt)
+			}
+			flat.options[opt] = s.options[opt]
+			if s.bare[opt] {
+				flat.bare[opt] = true
+			} else {
+				delete(flat.bare, opt)
+			}
+		}
+		s.mutex.RUnlock()
+	}
+	return flat, nil
+}
+
+// Extends returns the names of the sections the first section with the given name inherits options from,
+// nearest first, see Flattened.
+func (c *Configuration) Extends(name string) ([]string, error) {
+	chain, err := c.inheritance(name)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, s := range chain[1:] {
+		names = append(names, s.Name())
+	}
+	return names, nil
+}
+
+// inheritance returns the section with the given name followed by the sections it extends, nearest first
+func (c *Configuration) inheritance(name string) ([]*Section, error) {
+	var chain []*Section
+	var names []string
+	for {
+		s, err := c.Section(name)
+		if err != nil && len(chain) == 0 {
+			return nil, err
+		} else if err != nil {
+			return nil, fmt.Errorf("section %s extends %s, which doesn't exist", names[len(names)-1], name)
+		}
+		for _, n := range names {
+			if n == s.Name() {
+				return nil, fmt.Errorf("inheritance cycle: %s -> %s", strings.Join(names, " -> "), s.Name())
+			}
+		}
+		chain = append(chain, s)
+		names = append(names, s.Name())
+
+		if name = s.ValueOfWithoutComments(ExtendsOption); name == "" {
+			return chain, nil
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package configparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFlattened(t *testing.T) {
+	in := `[base]
+# shared settings
+host = localhost
+port = 80
+debug
+[web]
+extends = base # inherit
+port = 8080
+[web-canary]
+extends = web
+canary = true
+debug = false
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flat, err := conf.Flattened("web-canary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := strings.NewReplacer(" = ", Delimiter).Replace(`[web-canary]
+host = localhost
+port = 8080
+debug = false
+canary = true
+`)
+	if flat.String() != exp {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, flat.String())
+	}
+	if names, err := conf.Extends("web-canary"); err != nil || !reflect.DeepEqual(names, []string{"web", "base"}) {
+		t.Fatalf("expected web and base, got %v, %v", names, err)
+	}
+
+	flat.Add("port", "1")
+	if v, _ := conf.StringValue("web", "port"); v != "8080" {
+		t.Fatal("expected the flattened section to be detached")
+	}
+	if flat, err := conf.Flattened("base"); err != nil || flat.State("debug") != StateBare {
+		t.Fatalf("expected bare option to be kept, got %v", err)
+	}
+}
+
+func TestFlattenedErrors(t *testing.T) {
+	in := `[a]
+extends = b
+[b]
+extends = c
+[c]
+extends = a
+[d]
+extends = missing
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"a":       "inheritance cycle: a -> b -> c -> a",
+		"d":       "section d extends missing, which doesn't exist",
+		"missing": "Unable to find missing",
+	}
+	for name, exp := range tests {
+		if _, err := conf.Flattened(name); err == nil || err.Error() != exp {
+			t.Fatalf("%s: expected error %q, got %v", name, exp, err)
+		}
+	}
+}
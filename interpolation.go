@@ -0,0 +1,100 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultInterpolationDepth limits how deep references to other options are followed, see WithInterpolationDepth
+const defaultInterpolationDepth = 10
+
+// InterpolatedValueOf returns the value of the specified option with all %(name)s references replaced by
+// the value of the option name, which is looked up in the same section first and then in the global section.
+// Referenced values are interpolated as well. %% is replaced by a literal %.
+// This follows the semantics of Python's configparser.BasicInterpolation.
+// References that form a cycle, or chains of references deeper than the limit set with WithInterpolationDepth,
+// return an error naming the options involved.
+func (s *Section) InterpolatedValueOf(option string) (string, error) {
+	return s.interpolate(s.RawValueOf(option), []string{s.opts.optionName(option)})
+}
+
+// maybeInterpolate interpolates the value of option if interpolation is enabled, returning it unchanged if that fails
+func (s *Section) maybeInterpolate(option, value string) string {
+	if s.opts == nil || !s.opts.interpolation {
+		return value
+	}
+	if v, err := s.interpolate(value, []string{s.opts.optionName(option)}); err == nil {
+		return v
+	}
+	return value
+}
+
+// interpolate expands the references in value, the value of the last option in chain,
+// which lists the options whose references led to it
+func (s *Section) interpolate(value string, chain []string) (string, error) {
+	if !strings.Contains(value, "%") {
+		return value, nil
+	}
+	maxDepth := defaultInterpolationDepth
+	if s.opts != nil && s.opts.maxDepth > 0 {
+		maxDepth = s.opts.maxDepth
+	}
+	if len(chain) > maxDepth {
+		return "", fmt.Errorf("interpolation in section %q exceeds max depth of %d: %s", s.Name(), maxDepth, strings.Join(chain, " -> "))
+	}
+
+	var b strings.Builder
+	rest := value
+	for {
+		i := strings.IndexByte(rest, '%')
+		if i == -1 {
+			b.WriteString(rest)
+			return b.String(), nil
+		}
+		b.WriteString(rest[:i])
+		rest = rest[i+1:]
+
+		switch {
+		case strings.HasPrefix(rest, "%"):
+			b.WriteByte('%')
+			rest = rest[1:]
+		case strings.HasPrefix(rest, "("):
+			end := strings.Index(rest, ")s")
+			if end == -1 {
+				return "", fmt.Errorf("bad interpolation syntax in %q", value)
+			}
+			name := rest[1:end]
+			for _, opt := range chain {
+				if opt == s.opts.optionName(name) {
+					return "", fmt.Errorf("reference cycle in section %q: %s -> %s", s.Name(), strings.Join(chain, " -> "), name)
+				}
+			}
+			ref, ok := s.lookup(name)
+			if !ok {
+				return "", fmt.Errorf("option %q referenced in section %q not found", name, s.Name())
+			}
+			ref, err := s.interpolate(ref, append(chain[:len(chain):len(chain)], s.opts.optionName(name)))
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(ref)
+			rest = rest[end+2:]
+		default:
+			return "", fmt.Errorf("'%%' must be followed by '%%' or '(' in %q", value)
+		}
+	}
+}
+
+// lookup returns the raw value of the option in s, or in the global section if s doesn't have it
+func (s *Section) lookup(option string) (string, bool) {
+	for _, section := range []*Section{s, s.defaults} {
+		if section != nil && section.Exists(option) {
+			return section.RawValueOf(option), true
+		}
+	}
+	return "", false
+}
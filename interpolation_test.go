@@ -0,0 +1,107 @@
+package configparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInterpolation(t *testing.T) {
+	in := `home = /home/joe
+[paths]
+data = %(home)s/data
+logs = %(data)s/logs
+discount = 20%%
+escaped = %%(home)s
+comment = %(home)s/x # where it lives
+missing = %(nope)s
+bad = 100%
+`
+	conf, err := Read(strings.NewReader(in), "", WithInterpolation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("paths")
+
+	exp := map[string]string{
+		"data":     "/home/joe/data",
+		"logs":     "/home/joe/data/logs",
+		"discount": "20%",
+		"escaped":  "%(home)s",
+		"missing":  "%(nope)s",
+		"bad":      "100%",
+	}
+	for opt, want := range exp {
+		if got := s.ValueOf(opt); got != want {
+			t.Fatalf("option %q: expected %q, got %q", opt, want, got)
+		}
+	}
+	if got := s.ValueOfWithoutComments("comment"); got != "/home/joe/x" {
+		t.Fatalf("expected comment to be stripped after interpolation, got %q", got)
+	}
+	if got := s.RawValueOf("logs"); got != "%(data)s/logs" {
+		t.Fatalf("expected raw value, got %q", got)
+	}
+	for _, opt := range []string{"missing", "bad"} {
+		if _, err := s.InterpolatedValueOf(opt); err == nil {
+			t.Fatalf("option %q: expected interpolation error", opt)
+		}
+	}
+}
+
+func TestInterpolationDisabled(t *testing.T) {
+	conf, err := Read(strings.NewReader("a = 1\nb = %(a)s%%\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := conf.GlobalSection().ValueOf("b"); got != "%(a)s%%" {
+		t.Fatalf("expected value without interpolation, got %q", got)
+	}
+	if got, _ := conf.GlobalSection().InterpolatedValueOf("b"); got != "1%" {
+		t.Fatalf("expected explicit interpolation, got %q", got)
+	}
+}
+
+func TestInterpolationDepth(t *testing.T) {
+	conf, err := Read(strings.NewReader("a = %(b)s\nb = %(a)s\n"), "", WithInterpolation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conf.GlobalSection().InterpolatedValueOf("a"); err == nil {
+		t.Fatal("expected error for self-referencing options")
+	}
+}
+
+func TestInterpolationCycles(t *testing.T) {
+	in := `a = %(b)s
+b = x%(c)s
+c = %(a)s
+[foo]
+self = %(self)s
+d0 = %(d1)s
+d1 = %(d2)s
+d2 = %(d3)s
+d3 = %(d4)s
+d4 = end
+`
+	conf, err := Read(strings.NewReader(in), "", WithInterpolation(), WithInterpolationDepth(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conf.GlobalSection().InterpolatedValueOf("a")
+	if exp := `reference cycle in section "": a -> b -> c -> a`; err == nil || err.Error() != exp {
+		t.Fatalf("expected error %q, got %v", exp, err)
+	}
+
+	s, _ := conf.Section("foo")
+	_, err = s.InterpolatedValueOf("self")
+	if exp := `reference cycle in section "foo": self -> self`; err == nil || err.Error() != exp {
+		t.Fatalf("expected error %q, got %v", exp, err)
+	}
+	if v, err := s.InterpolatedValueOf("d1"); err != nil || v != "end" {
+		t.Fatalf("expected a chain within the depth to work, got %q, %v", v, err)
+	}
+	_, err = s.InterpolatedValueOf("d0")
+	if exp := `interpolation in section "foo" exceeds max depth of 3: d0 -> d1 -> d2 -> d3`; err == nil || err.Error() != exp {
+		t.Fatalf("expected error %q, got %v", exp, err)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package configparser
+
+import (
+	"container/list"
+	"iter"
+)
+
+// All returns an iterator over the non-global Sections and their fully qualified names, in the
+// order they were parsed or added. The Configuration is not locked while the loop body runs,
+// so it may read or modify the Configuration; sections added during the loop may or may not be visited.
+func (c *Configuration) All() iter.Seq2[string, *Section] {
+	return func(yield func(string, *Section) bool) {
+		for i := 0; ; i++ {
+			c.mutex.RLock()
+			if i >= len(c.orderedSections) {
+				c.mutex.RUnlock()
+				return
+			}
+			fqn := c.orderedSections[i]
+			var e *list.Element
+			if lst, ok := c.sections[fqn]; ok {
+				e = lst.Front()
+			}
+			c.mutex.RUnlock()
+
+			for e != nil {
+				if !yield(fqn, e.Value.(*Section)) {
+					return
+				}
+				c.mutex.RLock()
+				e = e.Next()
+				c.mutex.RUnlock()
+			}
+		}
+	}
+}
+
+// All returns an iterator over the option names and values of the Section, in the same order as OptionNames.
+// Values are returned by ValueOf, so they are interpolated and transformed like when looked up by name;
+// use RawValueOf for the values as they were read. The Section is not locked while the loop body runs.
+func (s *Section) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for i := 0; ; i++ {
+			s.mutex.RLock()
+			if i >= len(s.orderedOptions) {
+				s.mutex.RUnlock()
+				return
+			}
+			opt := s.orderedOptions[i]
+			s.mutex.RUnlock()
+
+			if !yield(opt, s.ValueOf(opt)) {
+				return
+			}
+		}
+	}
+}
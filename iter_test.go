@@ -0,0 +1,65 @@
+//go:build go1.23
+
+package configparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIterators(t *testing.T) {
+	in := `[foo]
+a = 1
+b = 2
+[bar]
+c = 3
+[foo]
+d = 4
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	var options []string
+	for name, s := range conf.All() {
+		names = append(names, name)
+		for opt, value := range s.All() {
+			options = append(options, opt+"="+value)
+		}
+	}
+	if expected := []string{"foo", "foo", "bar"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected sections %v, got %v", expected, names)
+	}
+	if expected := []string{"a=1", "b=2", "d=4", "c=3"}; !reflect.DeepEqual(options, expected) {
+		t.Fatalf("expected options %v, got %v", expected, options)
+	}
+
+	// values are interpolated like with ValueOf
+	conf, err = Read(strings.NewReader("[foo]\nhost = example.com\nurl = http://%(host)s/\n"), "", WithInterpolation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	foo, _ := conf.Section("foo")
+	for opt, value := range foo.All() {
+		if value != foo.ValueOf(opt) {
+			t.Fatalf("option %s: expected %q, got %q", opt, foo.ValueOf(opt), value)
+		}
+	}
+	if foo.ValueOf("url") != "http://example.com/" {
+		t.Fatalf("unexpected value %q", foo.ValueOf("url"))
+	}
+
+	// the loop body may use the configuration without deadlocking, and break stops the iteration
+	n := 0
+	for range conf.All() {
+		conf.NewSection("baz")
+		n++
+		break
+	}
+	if n != 1 {
+		t.Fatalf("expected break to stop the iteration, got %d sections", n)
+	}
+}
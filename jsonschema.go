@@ -0,0 +1,135 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// jsonSchemaDialect is the JSON Schema version ToJSONSchema generates
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// ToJSONSchema returns a JSON Schema describing the configuration as a JSON object with an object per section,
+// holding the section's options, so editors and other tools can check the same constraints as Validate.
+// Options of type "int", "float" and "bool" are described as integers, numbers and booleans, others as strings.
+// Checks can't be expressed in JSON Schema and are left out, as is a Step whose Min is not 0.
+func (schema *Schema) ToJSONSchema() ([]byte, error) {
+	properties := make(map[string]interface{})
+	for _, ss := range schema.Sections {
+		properties[ss.Name] = ss.jsonSchema()
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"$schema":    jsonSchemaDialect,
+		"type":       "object",
+		"properties": properties,
+	}, "", "  ")
+}
+
+func (ss *SectionSchema) jsonSchema() map[string]interface{} {
+	properties := make(map[string]interface{})
+	for _, opt := range ss.Options {
+		properties[opt.Name] = opt.jsonSchema()
+	}
+	js := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if ss.Description != "" {
+		js["description"] = ss.Description
+	}
+	if ss.Constant {
+		js["readOnly"] = true
+	}
+
+	var all []interface{}
+	for _, group := range ss.ExactlyOneOf {
+		var oneOf []interface{}
+		for _, opt := range group {
+			oneOf = append(oneOf, map[string]interface{}{"required": []string{opt}})
+		}
+		all = append(all, map[string]interface{}{"oneOf": oneOf})
+	}
+	for _, group := range ss.AtMostOneOf {
+		// no two options of the group may be present together
+		var pairs []interface{}
+		for i := range group {
+			for j := i + 1; j < len(group); j++ {
+				pairs = append(pairs, map[string]interface{}{"required": []string{group[i], group[j]}})
+			}
+		}
+		if len(pairs) > 0 {
+			all = append(all, map[string]interface{}{"not": map[string]interface{}{"anyOf": pairs}})
+		}
+	}
+	if len(all) > 0 {
+		js["allOf"] = all
+	}
+	if len(ss.Requires) > 0 {
+		js["dependentRequired"] = ss.Requires
+	}
+	return js
+}
+
+func (opt *OptionSchema) jsonSchema() map[string]interface{} {
+	js := map[string]interface{}{"type": jsonType(opt.Type)}
+	if opt.Description != "" {
+		js["description"] = opt.Description
+	}
+	if opt.hasDefault() {
+		js["default"] = jsonValue(opt.Type, opt.Default)
+	}
+	if opt.Pattern != "" {
+		js["pattern"] = "^(?:" + opt.Pattern + ")$"
+	}
+	if opt.Min != nil {
+		js["minimum"] = *opt.Min
+	}
+	if opt.Max != nil {
+		js["maximum"] = *opt.Max
+	}
+	if opt.Step != nil && *opt.Step > 0 && (opt.Min == nil || *opt.Min == 0) {
+		js["multipleOf"] = *opt.Step
+	}
+	if opt.RemovedIn != "" {
+		js["deprecated"] = true
+	}
+	if opt.Constant {
+		js["readOnly"] = true
+	}
+	return js
+}
+
+// jsonType returns the JSON Schema type for an OptionSchema Type
+func jsonType(typ string) string {
+	switch typ {
+	case "int":
+		return "integer"
+	case "float":
+		return "number"
+	case "bool":
+		return "boolean"
+	}
+	return "string"
+}
+
+// jsonValue converts value to the JSON type for typ, or leaves it a string if it can't
+func jsonValue(typ, value string) interface{} {
+	switch typ {
+	case "int":
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+	case "float":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case "bool":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
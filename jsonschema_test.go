@@ -0,0 +1,114 @@
+package configparser
+
+import "testing"
+
+func TestToJSONSchema(t *testing.T) {
+	schema := &Schema{
+		Sections: []SectionSchema{
+			{
+				Name:        "server",
+				Description: "HTTP server",
+				Options: []OptionSchema{
+					{Name: "listen", Description: "address", Pattern: `[^:]*:[0-9]+`, Default: ":80"},
+					{Name: "workers", Type: "int", Min: Float(1), Max: Float(64), Default: "4"},
+					{Name: "ratio", Type: "float", Step: Float(0.25)},
+					{Name: "debug", Type: "bool", Default: "false"},
+					{Name: "addr", RemovedIn: "2.0", ReplacedBy: "listen"},
+				},
+				ExactlyOneOf: [][]string{{"listen", "socket"}},
+				AtMostOneOf:  [][]string{{"debug", "quiet", "verbose"}},
+				Requires:     map[string][]string{"tls_cert": {"tls_key"}},
+			},
+		},
+		Checks: []Check{func(*Configuration) []Violation { return nil }},
+	}
+
+	exp := `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "properties": {
+    "server": {
+      "allOf": [
+        {
+          "oneOf": [
+            {
+              "required": [
+                "listen"
+              ]
+            },
+            {
+              "required": [
+                "socket"
+              ]
+            }
+          ]
+        },
+        {
+          "not": {
+            "anyOf": [
+              {
+                "required": [
+                  "debug",
+                  "quiet"
+                ]
+              },
+              {
+                "required": [
+                  "debug",
+                  "verbose"
+                ]
+              },
+              {
+                "required": [
+                  "quiet",
+                  "verbose"
+                ]
+              }
+            ]
+          }
+        }
+      ],
+      "dependentRequired": {
+        "tls_cert": [
+          "tls_key"
+        ]
+      },
+      "description": "HTTP server",
+      "properties": {
+        "addr": {
+          "deprecated": true,
+          "type": "string"
+        },
+        "debug": {
+          "default": false,
+          "type": "boolean"
+        },
+        "listen": {
+          "default": ":80",
+          "description": "address",
+          "pattern": "^(?:[^:]*:[0-9]+)$",
+          "type": "string"
+        },
+        "ratio": {
+          "multipleOf": 0.25,
+          "type": "number"
+        },
+        "workers": {
+          "default": 4,
+          "maximum": 64,
+          "minimum": 1,
+          "type": "integer"
+        }
+      },
+      "type": "object"
+    }
+  },
+  "type": "object"
+}`
+	got, err := schema.ToJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != exp {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, got)
+	}
+}
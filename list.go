@@ -0,0 +1,87 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ListOf returns the value of the option split at sep, e.g. ',', with the whitespace around items removed.
+// Items may be quoted with single or double quotes to include sep or surrounding whitespace, e.g. `"a,b", c`,
+// and a backslash escapes the next character outside single quotes, e.g. `a\,b`. An unbalanced quote runs to
+// the end of the value, see StrictListOf to reject it instead. An empty value has no items.
+func (s *Section) ListOf(option string, sep rune) []string {
+	items, _ := splitList(s.ValueOf(option), sep, false)
+	return items
+}
+
+// StrictListOf is like ListOf, but returns an error for unbalanced quotes and a trailing backslash.
+func (s *Section) StrictListOf(option string, sep rune) ([]string, error) {
+	items, err := splitList(s.ValueOf(option), sep, true)
+	if err != nil {
+		return nil, fmt.Errorf("option %q: %s", option, err)
+	}
+	return items, nil
+}
+
+// splitList splits value at sep, honoring quotes and backslash escapes, see ListOf
+func splitList(value string, sep rune, strict bool) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var items []string
+	var item strings.Builder
+	var quote rune // the quote character of the quoted part we're in, or 0
+	end := 0       // the length of item without trailing unquoted whitespace
+	escaped := false
+
+	add := func(r rune) {
+		item.WriteRune(r)
+		end = item.Len()
+	}
+	for _, r := range value {
+		switch {
+		case escaped:
+			add(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				add(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			end = item.Len()
+		case r == sep:
+			items = append(items, item.String()[:end])
+			item.Reset()
+			end = 0
+		case unicode.IsSpace(r):
+			if item.Len() > 0 {
+				item.WriteRune(r)
+			}
+		default:
+			add(r)
+		}
+	}
+
+	if escaped {
+		if strict {
+			return nil, errors.New("trailing backslash")
+		}
+		add('\\')
+	}
+	if quote != 0 && strict {
+		return nil, fmt.Errorf("unbalanced %c quote", quote)
+	}
+	return append(items, item.String()[:end]), nil
+}
@@ -0,0 +1,68 @@
+package configparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected []string
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"a", []string{"a"}},
+		{"a, b ,c", []string{"a", "b", "c"}},
+		{"a,,b,", []string{"a", "", "b", ""}},
+		{`"a,b", c`, []string{"a,b", "c"}},
+		{`'a,b',"c"`, []string{"a,b", "c"}},
+		{`" a ", b`, []string{" a ", "b"}},
+		{`a\,b,c`, []string{"a,b", "c"}},
+		{`"say \"hi\"",x`, []string{`say "hi"`, "x"}},
+		{`'C:\dir',x`, []string{`C:\dir`, "x"}},
+		{`pre"a,b"post, x y`, []string{"prea,bpost", "x y"}},
+		{`"",x`, []string{"", "x"}},
+		{`"a,b`, []string{"a,b"}},
+		{`a\`, []string{`a\`}},
+	}
+	for _, test := range tests {
+		items, err := splitList(test.in, ',', false)
+		if err != nil {
+			t.Fatalf("%q: unexpected error %s", test.in, err)
+		}
+		if !reflect.DeepEqual(items, test.expected) {
+			t.Fatalf("%q: expected %q, got %q", test.in, test.expected, items)
+		}
+	}
+}
+
+func TestListOf(t *testing.T) {
+	in := `[foo]
+hosts = "db:1,2", web ; cache
+bad = "a, b
+path = /a:/b
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("foo")
+	if items := s.ListOf("hosts", ';'); !reflect.DeepEqual(items, []string{`db:1,2, web`, "cache"}) {
+		t.Fatalf("unexpected items %q", items)
+	}
+	if items := s.ListOf("path", ':'); !reflect.DeepEqual(items, []string{"/a", "/b"}) {
+		t.Fatalf("unexpected items %q", items)
+	}
+	if items := s.ListOf("missing", ','); items != nil {
+		t.Fatalf("expected no items, got %q", items)
+	}
+
+	if _, err := s.StrictListOf("bad", ','); err == nil || err.Error() != `option "bad": unbalanced " quote` {
+		t.Fatalf("expected error for unbalanced quote, got %v", err)
+	}
+	if items, err := s.StrictListOf("hosts", ','); err != nil || !reflect.DeepEqual(items, []string{"db:1,2", "web ; cache"}) {
+		t.Fatalf("unexpected items %q, %v", items, err)
+	}
+}
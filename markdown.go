@@ -0,0 +1,165 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// WriteMarkdown writes a reference of the sections and options of conf and schema to w as Markdown.
+// Either of them may be nil. Sections and options described by the schema come first, in schema order,
+// followed by those only found in conf. Descriptions are taken from the schema, or else from the comment
+// lines directly above an option in conf. Repeated sections are documented once.
+func WriteMarkdown(w io.Writer, conf *Configuration, schema *Schema) error {
+	if schema == nil {
+		schema = &Schema{}
+	}
+	var global *Section
+	var sections []*Section
+	if conf != nil {
+		global, sections, _ = conf.AllSections()
+	}
+
+	bw := bufio.NewWriter(w)
+	if gs := schema.section(""); gs != nil || (global != nil && len(docOptions(global, nil)) > 0) {
+		writeMarkdownSection(bw, "Global options", gs.description(), global, gs)
+	}
+
+	documented := make(map[string]bool)
+	for i := range schema.Sections {
+		ss := &schema.Sections[i]
+		if ss.Name == "" {
+			continue
+		}
+		documented[ss.Name] = true
+		var s *Section
+		if conf != nil {
+			s, _ = conf.Section(ss.Name)
+		}
+		writeMarkdownSection(bw, "["+ss.Name+"]", ss.Description, s, ss)
+	}
+	for _, s := range sections {
+		if documented[s.Name()] {
+			continue
+		}
+		documented[s.Name()] = true
+		writeMarkdownSection(bw, "["+s.Name()+"]", "", s, nil)
+	}
+	return bw.Flush()
+}
+
+type docOption struct {
+	name, typ, def, description string
+}
+
+// description returns the description of the section, or "" if ss is nil
+func (ss *SectionSchema) description() string {
+	if ss == nil {
+		return ""
+	}
+	return ss.Description
+}
+
+// docOptions returns the documentation of the options of s and ss, either of which may be nil
+func docOptions(s *Section, ss *SectionSchema) []docOption {
+	var docs []docOption
+	comments := make(map[string]string)
+	if s != nil {
+		comments = attachedComments(s)
+	}
+	if ss != nil {
+		for _, opt := range ss.Options {
+			description := opt.Description
+			if description == "" {
+				description = comments[opt.Name]
+			}
+			def := opt.Default
+			if def == "" && opt.HasDefault {
+				def = `""`
+			}
+			docs = append(docs, docOption{opt.Name, opt.Type, def, description})
+		}
+	}
+	if s != nil {
+		for _, opt := range s.OptionNames() {
+			if opt == "" || s.opts.isComment(opt) || ss.option(opt) != nil {
+				continue
+			}
+			docs = append(docs, docOption{opt, "", "", comments[opt]})
+		}
+	}
+	return docs
+}
+
+// attachedComments returns the text of the comment lines directly above each option of s, as they were read
+// if s was read from a source, see RawLines
+func attachedComments(s *Section) map[string]string {
+	var lines []string
+	for _, raw := range s.RawLines() {
+		// lines continued with a backslash are kept together
+		lines = append(lines, strings.Split(raw, "\n")...)
+	}
+	if len(lines) == 0 {
+		for _, opt := range s.OptionNames() {
+			// comments are split at the delimiter like options, so the line is rebuilt as it is written
+			line := opt
+			if state := s.State(opt); state == StateSet {
+				line += Delimiter + s.RawValueOf(opt)
+			} else if state == StateEmpty {
+				line += strings.TrimRight(Delimiter, " ")
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	attached := make(map[string]string)
+	var comments []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			comments = nil
+		case s.opts.isComment(line):
+			_, size := utf8.DecodeRuneInString(line)
+			comments = append(comments, strings.TrimSpace(line[size:]))
+		default:
+			if opt, _ := s.opts.parseOption(line); len(comments) > 0 {
+				attached[s.opts.optionName(opt)] = strings.Join(comments, " ")
+			}
+			comments = nil
+		}
+	}
+	return attached
+}
+
+func writeMarkdownSection(w *bufio.Writer, title, description string, s *Section, ss *SectionSchema) {
+	fmt.Fprintf(w, "## %s\n\n", title)
+	if description != "" {
+		fmt.Fprintf(w, "%s\n\n", description)
+	}
+	docs := docOptions(s, ss)
+	if len(docs) == 0 {
+		return
+	}
+	w.WriteString("| Option | Type | Default | Description |\n")
+	w.WriteString("| --- | --- | --- | --- |\n")
+	for _, doc := range docs {
+		def := doc.def
+		if def != "" {
+			def = "`" + def + "`"
+		}
+		fmt.Fprintf(w, "| `%s` | %s | %s | %s |\n", escapeCell(doc.name), escapeCell(doc.typ), escapeCell(def), escapeCell(doc.description))
+	}
+	w.WriteString("\n")
+}
+
+// escapeCell escapes the pipes in the content of a Markdown table cell
+func escapeCell(s string) string {
+	return strings.Replace(s, "|", `\|`, -1)
+}
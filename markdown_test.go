@@ -0,0 +1,85 @@
+package configparser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	in := `# the name of the app
+name = demo
+[server]
+# address to listen on
+# (all interfaces if empty)
+host = 0.0.0.0
+
+# not attached to anything
+
+port = 80
+[cache]
+# size | in MB
+# e.g. size=10
+size = 10
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := &Schema{
+		Sections: []SectionSchema{
+			{
+				Name:        "server",
+				Description: "The HTTP server.",
+				Options: []OptionSchema{
+					{Name: "port", Type: "int", Default: "8080", Description: "port to listen on"},
+					{Name: "host", Type: "string"},
+				},
+			},
+			{
+				Name: "log",
+				Options: []OptionSchema{
+					{Name: "level", Default: "info"},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&buf, conf, schema); err != nil {
+		t.Fatal(err)
+	}
+	exp := "## Global options\n\n" +
+		"| Option | Type | Default | Description |\n| --- | --- | --- | --- |\n" +
+		"| `name` |  |  | the name of the app |\n\n" +
+		"## [server]\n\nThe HTTP server.\n\n" +
+		"| Option | Type | Default | Description |\n| --- | --- | --- | --- |\n" +
+		"| `port` | int | `8080` | port to listen on |\n" +
+		"| `host` | string |  | address to listen on (all interfaces if empty) |\n\n" +
+		"## [log]\n\n" +
+		"| Option | Type | Default | Description |\n| --- | --- | --- | --- |\n" +
+		"| `level` |  | `info` |  |\n\n" +
+		"## [cache]\n\n" +
+		"| Option | Type | Default | Description |\n| --- | --- | --- | --- |\n" +
+		"| `size` |  |  | size \\| in MB e.g. size=10 |\n\n"
+	if buf.String() != exp {
+		t.Fatalf("markdown mismatch\nexp:\n%s\ngot:\n%s", exp, buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteMarkdown(&buf, nil, schema); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "## [server]") {
+		t.Fatalf("unexpected markdown for schema only:\n%s", buf.String())
+	}
+
+	// sections built in code have no source lines to take comments from
+	built := NewConfiguration()
+	s := built.NewSection("cache")
+	s.Add("# size in MB", "")
+	s.Add("size", "10")
+	if comments := attachedComments(s); comments["size"] != "size in MB" {
+		t.Fatalf("expected the comment to be attached, got %q", comments)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// A SaveTarget stores the files written by Save in place of the file system, see WithSaveTarget.
+type SaveTarget interface {
+	WriteFile(path string, data []byte) error
+}
+
+// WithSaveTarget makes Save, and the Save method, write the Configuration to target instead of the file system,
+// e.g. a MemFS in tests of code that saves configurations. Backups and file options such as WithFileMode
+// don't apply.
+func WithSaveTarget(target SaveTarget) Option {
+	return func(o *options) {
+		o.target = target
+	}
+}
+
+// MemFS is an in-memory SaveTarget, which records what Save would have written so tests can inspect it.
+// It is safe for concurrent use.
+type MemFS struct {
+	mutex sync.Mutex
+	files map[string][]byte
+}
+
+// NewMemFS returns an empty MemFS.
+func NewMemFS() *MemFS {
+	return &MemFS{files: make(map[string][]byte)}
+}
+
+// WriteFile stores data as the contents of the file at path, replacing any earlier contents.
+func (fs *MemFS) WriteFile(path string, data []byte) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	fs.files[filepath.Clean(path)] = append([]byte(nil), data...)
+	return nil
+}
+
+// ReadFile returns the contents of the file at path, or an error satisfying os.IsNotExist if there is none.
+func (fs *MemFS) ReadFile(path string) ([]byte, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	data, ok := fs.files[filepath.Clean(path)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// Files returns the paths of all files written, sorted.
+func (fs *MemFS) Files() []string {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	paths := make([]string, 0, len(fs.files))
+	for path := range fs.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// saveToTarget writes c to the SaveTarget of its options, see WithSaveTarget
+func saveToTarget(c *Configuration, filePath string, o *saveOptions) (int64, error) {
+	var buf bytes.Buffer
+	if err := o.write(c, &buf); err != nil {
+		return 0, err
+	}
+	if err := c.opts.target.WriteFile(filePath, buf.Bytes()); err != nil {
+		return 0, err
+	}
+	c.opts.log().Debug("saved configuration", "file", filePath, "bytes", buf.Len())
+	return int64(buf.Len()), nil
+}
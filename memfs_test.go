@@ -0,0 +1,43 @@
+package configparser
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMemFS(t *testing.T) {
+	fs := NewMemFS()
+	path := "/nonexistent/dir/app.ini"
+
+	conf, err := Read(strings.NewReader("[foo]\na = 1\n"), path, WithSaveTarget(fs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("foo")
+	s.SetValueFor("a", "2")
+	if err := conf.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Save(conf, "/other.ini", WithFileMode(0600)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing to be written to disk, got %v", err)
+	}
+	if files := fs.Files(); !reflect.DeepEqual(files, []string{"/nonexistent/dir/app.ini", "/other.ini"}) {
+		t.Fatalf("unexpected files %v", files)
+	}
+	data, err := fs.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "[foo]\na"+Delimiter+"2\n" {
+		t.Fatalf("unexpected contents %q", data)
+	}
+	if _, err := fs.ReadFile("/missing.ini"); !os.IsNotExist(err) {
+		t.Fatalf("expected a not exist error, got %v", err)
+	}
+}
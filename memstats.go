@@ -0,0 +1,90 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"container/list"
+	"unsafe"
+)
+
+// MemStats is an estimate of the memory held by a Configuration, see Configuration.MemStats.
+type MemStats struct {
+	Sections int // number of sections, including the global section
+	Options  int // number of options, including comments and empty lines
+
+	SectionBytes int // section structs, names, headers and metadata
+	OptionBytes  int // option names and values, their order, line numbers, states and metadata
+	LineBytes    int // source lines kept for RawLines and Grep
+	TotalBytes   int
+}
+
+// approximate sizes of the values held by sections; maps are estimated by their entries, plus a fixed
+// overhead for each entry that covers buckets, hashes and unused slots
+const (
+	stringSize   = int(unsafe.Sizeof(""))
+	intSize      = int(unsafe.Sizeof(0))
+	pointerSize  = int(unsafe.Sizeof(uintptr(0)))
+	mapEntrySize = 2 * pointerSize
+	mapSize      = 6 * pointerSize
+	sectionSize  = int(unsafe.Sizeof(Section{}))
+	elementSize  = int(unsafe.Sizeof(list.Element{}))
+)
+
+// MemStats estimates the bytes held by the sections of the configuration, their options and the source
+// lines kept with them, e.g. to see how much memory very large files take once read. The estimate counts
+// the contents of strings, maps and slices, but not memory shared with other configurations or the
+// unused capacity of slices, so it is a lower bound rather than an exact figure.
+func (c *Configuration) MemStats() MemStats {
+	global, sections, _ := c.AllSections()
+
+	var m MemStats
+	for _, s := range append([]*Section{global}, sections...) {
+		s.memStats(&m)
+	}
+	// the list elements and ordered names of non-global sections
+	m.SectionBytes += len(sections) * (elementSize + stringSize)
+	m.TotalBytes = m.SectionBytes + m.OptionBytes + m.LineBytes
+	return m
+}
+
+// memStats adds the estimated size of the section to m, see Configuration.MemStats
+func (s *Section) memStats(m *MemStats) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	m.Sections++
+	m.SectionBytes += sectionSize + len(s.fqn) + len(s.file) + len(s.header) + stringsMapSize(s.meta)
+
+	m.Options += len(s.orderedOptions)
+	for _, opt := range s.orderedOptions {
+		// the name is shared by the order, the options map and the maps below
+		m.OptionBytes += stringSize + len(opt) + 2*stringSize + mapEntrySize + len(s.options[opt])
+	}
+	m.OptionBytes += len(s.lines)*(stringSize+intSize+mapEntrySize) + len(s.bare)*(stringSize+1+mapEntrySize)
+	for _, meta := range s.optionMeta {
+		m.OptionBytes += stringSize + pointerSize + mapEntrySize + stringsMapSize(meta)
+	}
+	for _, size := range []int{len(s.options), len(s.lines), len(s.bare), len(s.optionMeta)} {
+		if size > 0 {
+			m.OptionBytes += mapSize
+		}
+	}
+
+	for _, line := range s.rawLines {
+		m.LineBytes += stringSize + len(line)
+	}
+}
+
+// stringsMapSize returns the estimated size of a map of strings
+func stringsMapSize(m map[string]string) int {
+	if len(m) == 0 {
+		return 0
+	}
+	size := mapSize
+	for k, v := range m {
+		size += 2*stringSize + mapEntrySize + len(k) + len(v)
+	}
+	return size
+}
@@ -0,0 +1,33 @@
+package configparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMemStats(t *testing.T) {
+	conf, err := Read(strings.NewReader("a = 1\n[foo]\n# comment\nb = 2\n\n[bar]\nc = 3\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := conf.MemStats()
+	if m.Sections != 3 || m.Options != 5 {
+		t.Fatalf("expected 3 sections and 5 options, got %d and %d", m.Sections, m.Options)
+	}
+	if m.SectionBytes <= 0 || m.OptionBytes <= 0 || m.LineBytes <= len("a = 1[foo]# commentb = 2[bar]c = 3") {
+		t.Fatalf("unexpected sizes %+v", m)
+	}
+	if m.TotalBytes != m.SectionBytes+m.OptionBytes+m.LineBytes {
+		t.Fatalf("expected total to be the sum of sizes, got %+v", m)
+	}
+
+	s, _ := conf.Section("bar")
+	s.SetValueFor("c", strings.Repeat("3", 1001))
+	if grown := conf.MemStats(); grown.OptionBytes-m.OptionBytes != 1000 || grown.LineBytes != m.LineBytes {
+		t.Fatalf("expected options to grow by 1000 bytes, got %+v after %+v", grown, m)
+	}
+
+	if m := NewConfiguration().MemStats(); m.Sections != 1 || m.Options != 0 || m.LineBytes != 0 {
+		t.Fatalf("unexpected stats for an empty configuration %+v", m)
+	}
+}
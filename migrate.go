@@ -0,0 +1,146 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VersionOption is the name of the global option holding the version of a configuration file.
+// Files without it are at version 1.
+const VersionOption = "version"
+
+// Migration transforms a Configuration from one version to the next.
+type Migration func(c *Configuration) error
+
+// Version returns the version of the configuration, as set in the global VersionOption.
+func (c *Configuration) Version() (int, error) {
+	value := c.GlobalSection().ValueOfWithoutComments(VersionOption)
+	if value == "" {
+		return 1, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid %s %q", VersionOption, value)
+	}
+	return version, nil
+}
+
+// Migrate upgrades the configuration to the latest version by applying the migrations it hasn't had yet, in order.
+// migrations[i] upgrades version i+1 to version i+2, so the latest version is len(migrations)+1.
+// The version option is updated after every successful migration.
+// It returns whether any migrations were applied, and an error if one of them failed or if the configuration
+// is newer than the latest version.
+func Migrate(c *Configuration, migrations ...Migration) (migrated bool, err error) {
+	version, err := c.Version()
+	if err != nil {
+		return false, err
+	}
+	if version > len(migrations)+1 {
+		return false, fmt.Errorf("configuration version %d is newer than the latest supported version %d", version, len(migrations)+1)
+	}
+	for _, migration := range migrations[version-1:] {
+		err = migration(c)
+		if err != nil {
+			return migrated, fmt.Errorf("migrating from version %d to %d: %v", version, version+1, err)
+		}
+		version++
+		c.GlobalSection().Add(VersionOption, strconv.Itoa(version))
+		migrated = true
+	}
+	return migrated, nil
+}
+
+// ReadFileMigrated reads a configuration file and migrates it to the latest version, see Migrate.
+// If persist is true and any migrations were applied, the upgraded configuration is saved back to the file.
+func ReadFileMigrated(filePath string, persist bool, migrations []Migration, opts ...Option) (*Configuration, error) {
+	c, err := ReadFile(filePath, opts...)
+	if err != nil {
+		return nil, err
+	}
+	migrated, err := Migrate(c, migrations...)
+	if err != nil {
+		return nil, err
+	}
+	if migrated && persist {
+		err = Save(c, c.FilePath())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// RenameOptionEverywhere renames an option in the global section and all other sections, e.g. for a migration
+// from "hostname" to "host", see Section.RenameOption. It returns the number of sections the option was renamed in,
+// and an error listing the sections it wasn't renamed in because it is constant there, see MarkConstant, or
+// they already have an option with the new name.
+func (c *Configuration) RenameOptionEverywhere(old, new string) (int, error) {
+	global, sections, _ := c.AllSections()
+	n := 0
+	var skipped []string
+	for _, s := range append([]*Section{global}, sections...) {
+		switch {
+		case old == new || !s.Exists(old):
+		case s.RenameOption(old, new):
+			n++
+		default:
+			skipped = append(skipped, skippedRename(s, old, new))
+		}
+	}
+	return n, renameConflicts(skipped)
+}
+
+// RenameOptionsMatching renames the options matching the regexp in all sections, including the global section,
+// to the replacement, which may refer to submatches of the regexp like regexp.ReplaceAllString does,
+// e.g. RenameOptionsMatching(`^db_(.*)$`, "database_$1"). Comments are left alone.
+// It returns the number of options renamed, counting each section individually. Constants, see MarkConstant,
+// and options whose new name is already taken in their section, including by an option renamed before them,
+// are left alone and listed in the error, which is also returned for an invalid regexp.
+func (c *Configuration) RenameOptionsMatching(regex, replacement string) (int, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return 0, err
+	}
+	global, sections, _ := c.AllSections()
+	n := 0
+	var skipped []string
+	for _, s := range append([]*Section{global}, sections...) {
+		for _, opt := range append([]string(nil), s.OptionNames()...) {
+			if opt == "" || s.opts.isComment(opt) || !re.MatchString(opt) {
+				continue
+			}
+			new := re.ReplaceAllString(opt, replacement)
+			switch {
+			case new == opt:
+			case s.RenameOption(opt, new):
+				n++
+			default:
+				skipped = append(skipped, skippedRename(s, opt, new))
+			}
+		}
+	}
+	return n, renameConflicts(skipped)
+}
+
+// skippedRename describes why old wasn't renamed to new in s, see Section.RenameOption
+func skippedRename(s *Section, old, new string) string {
+	reason := "the new name is taken"
+	if s.IsConstant(old) {
+		reason = "it is constant"
+	}
+	return fmt.Sprintf("%s in [%s] to %s: %s", old, s.Name(), new, reason)
+}
+
+// renameConflicts returns an error listing the skipped renames, or nil if there are none
+func renameConflicts(skipped []string) error {
+	if len(skipped) == 0 {
+		return nil
+	}
+	return fmt.Errorf("options not renamed: %s", strings.Join(skipped, "; "))
+}
@@ -0,0 +1,186 @@
+package configparser
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testMigrations = []Migration{
+	// v1 -> v2: rename hostname to host
+	func(c *Configuration) error {
+		s, err := c.Section("server")
+		if err != nil {
+			return err
+		}
+		s.Add("host", s.Delete("hostname"))
+		return nil
+	},
+	// v2 -> v3: add a timeout
+	func(c *Configuration) error {
+		_, err := c.SetValue("server", "timeout", "30s")
+		return err
+	},
+}
+
+func TestMigrate(t *testing.T) {
+	conf, err := Read(strings.NewReader("[server]\nhostname = example.com\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrated, err := Migrate(conf, testMigrations...)
+	if err != nil || !migrated {
+		t.Fatalf("expected migration, got %t, %v", migrated, err)
+	}
+	exp := "version" + Delimiter + "3\n[server]\nhost" + Delimiter + "example.com\ntimeout" + Delimiter + "30s\n"
+	if conf.String() != exp {
+		t.Fatalf("migrated config mismatch\nexp %q\ngot %q", exp, conf.String())
+	}
+
+	migrated, err = Migrate(conf, testMigrations...)
+	if err != nil || migrated {
+		t.Fatalf("expected no migration of the latest version, got %t, %v", migrated, err)
+	}
+
+	conf, _ = Read(strings.NewReader("version = 2\n[server]\nhost = example.com\n"), "")
+	if _, err := Migrate(conf, testMigrations...); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := conf.StringValue("server", "timeout"); v != "30s" {
+		t.Fatal("expected only the second migration to be applied")
+	}
+
+	for _, in := range []string{"version = 4\n", "version = x\n", "version = 0\n"} {
+		conf, _ = Read(strings.NewReader(in), "")
+		if _, err := Migrate(conf, testMigrations...); err == nil {
+			t.Fatalf("%q: expected error", in)
+		}
+	}
+
+	conf, _ = Read(strings.NewReader("[server]\nhostname = a\n"), "")
+	_, err = Migrate(conf, testMigrations[0], func(c *Configuration) error { return errors.New("boom") })
+	if err == nil {
+		t.Fatal("expected error from failing migration")
+	}
+	if v, _ := conf.Version(); v != 2 {
+		t.Fatalf("expected version of the last successful migration, got %d", v)
+	}
+}
+
+func TestReadFileMigrated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configparser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.ini")
+	if err := ioutil.WriteFile(path, []byte("[server]\nhostname = example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadFileMigrated(path, false, testMigrations); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(path); strings.Contains(string(data), "version") {
+		t.Fatal("expected file not to be persisted")
+	}
+
+	if _, err := ReadFileMigrated(path, true, testMigrations); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := conf.Version(); v != 3 {
+		t.Fatalf("expected persisted version 3, got %d", v)
+	}
+}
+
+func TestRenameOptionEverywhere(t *testing.T) {
+	in := `hostname = a
+[server]
+# hostname of the server
+hostname = b
+port = 80
+[client]
+host = old
+hostname = c
+[other]
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.GlobalSection().SetOptionMeta("hostname", "k", "v")
+	n, err := conf.RenameOptionEverywhere("hostname", "host")
+	if n != 2 || err == nil || err.Error() != "options not renamed: hostname in [client] to host: the new name is taken" {
+		t.Fatalf("expected 2 renames and a conflict in client, got %d, %v", n, err)
+	}
+	exp := strings.NewReplacer(" = ", Delimiter).Replace(`host = a
+[server]
+# hostname of the server
+host = b
+port = 80
+[client]
+host = old
+hostname = c
+[other]
+`)
+	if conf.String() != exp {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, conf.String())
+	}
+	if conf.GlobalSection().OptionMeta("host", "k") != "v" {
+		t.Fatal("expected metadata to be kept")
+	}
+	if s, _ := conf.Section("server"); s.LineOf("host") != 4 {
+		t.Fatalf("expected line to be kept, got %d", s.LineOf("host"))
+	}
+	client, _ := conf.Section("client")
+	client.Delete("host")
+	if n, err := conf.RenameOptionEverywhere("hostname", "host"); n != 1 || err != nil {
+		t.Fatalf("expected 1 rename, got %d, %v", n, err)
+	}
+	if n, err := conf.RenameOptionEverywhere("hostname", "host"); n != 0 || err != nil {
+		t.Fatalf("expected no renames, got %d, %v", n, err)
+	}
+}
+
+func TestRenameOptionsMatching(t *testing.T) {
+	conf, err := Read(strings.NewReader("[db]\ndb_host = a\ndb_port = 1\n# db_comment\n[cache]\ndb_size = 2\nttl = 3\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := conf.RenameOptionsMatching(`^db_(.*)$`, "database_$1")
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 renames, got %d, %v", n, err)
+	}
+	if v, _ := conf.StringValue("db", "database_port"); v != "1" {
+		t.Fatalf("expected renamed option, got %q", v)
+	}
+	if s, _ := conf.Section("db"); !s.Exists("# db_comment") {
+		t.Fatal("expected comments to be left alone")
+	}
+
+	// both options map to the same new name, the second is left alone
+	conf, err = Read(strings.NewReader("[s]\nold_a = 1\nnew_a = 2\nx_b = 3\ny_b = 4\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err = conf.RenameOptionsMatching(`^(old|x|y)_(.*)$`, "new_$2")
+	if n != 1 || err == nil || err.Error() != "options not renamed: old_a in [s] to new_a: the new name is taken; y_b in [s] to new_b: the new name is taken" {
+		t.Fatalf("expected 1 rename and 2 conflicts, got %d, %v", n, err)
+	}
+	exp := strings.NewReplacer(" = ", Delimiter).Replace("[s]\nold_a = 1\nnew_a = 2\nnew_b = 3\ny_b = 4\n")
+	if conf.String() != exp {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, conf.String())
+	}
+
+	if _, err := conf.RenameOptionsMatching("(", ""); err == nil {
+		t.Fatal("expected error for invalid regexp")
+	}
+}
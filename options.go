@@ -0,0 +1,376 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Option configures how a configuration is read. See Read.
+// New parse modes are added as new Options, so that the defaults never change underneath existing callers.
+type Option func(*options)
+
+// options holds the settings of a Configuration, as set by Options when it was read.
+// A nil *options is valid and holds the defaults.
+type options struct {
+	normalize     bool
+	encoding      encoding.Encoding
+	interpolation bool
+	strictOptions bool
+	strictHeaders bool
+	comments      string // characters that start a comment, "#" if empty
+	variables     map[string]string
+	logger        Logger
+	schema        *Schema
+	keyWhitespace KeyWhitespace
+	globalName    string
+	tracer        Tracer
+	duplicates    bool // see WithIndexedDuplicates
+	binary        BinaryPolicy
+	maxDepth      int // see WithInterpolationDepth
+	target        SaveTarget
+	transformers  []Transformer
+	delimiters    string // characters separating option names from values, "=" if empty
+	continuation  Continuation
+	foldSections  bool
+	foldOptions   bool
+	reuse         bool // see WithReuse
+	headerIndent  HeaderIndent
+	maxValueLen   int // see WithMaxValueLength
+	longValues    LongValuePolicy
+}
+
+// WithUnicodeNormalization normalizes section and option names to Unicode NFC, both when parsing
+// and when looking them up, so that visually identical names typed on different platforms
+// refer to the same section or option. Values are left as-is.
+func WithUnicodeNormalization() Option {
+	return func(o *options) {
+		o.normalize = true
+	}
+}
+
+// WithEncoding decodes the input from the given character encoding, such as
+// charmap.ISO8859_1 or charmap.Windows1252, instead of assuming it is UTF-8.
+// Note that Save and Write always produce UTF-8.
+func WithEncoding(enc encoding.Encoding) Option {
+	return func(o *options) {
+		o.encoding = enc
+	}
+}
+
+// WithInterpolation makes ValueOf and friends expand %(name)s references to other options
+// in the same section, or in the global section, with their values, see InterpolatedValueOf.
+func WithInterpolation() Option {
+	return func(o *options) {
+		o.interpolation = true
+	}
+}
+
+// WithInterpolationDepth sets how many levels of references are followed when interpolating,
+// 10 by default. It doesn't enable interpolation, see WithInterpolation.
+func WithInterpolationDepth(depth int) Option {
+	return func(o *options) {
+		o.maxDepth = depth
+	}
+}
+
+// WithStrictOptions rejects lines that are neither a section header, a comment, an empty line nor
+// an option with a delimiter (opt=value), instead of turning the entire line into an option name.
+func WithStrictOptions() Option {
+	return func(o *options) {
+		o.strictOptions = true
+	}
+}
+
+// WithComments sets the characters that start a comment, instead of only '#'.
+// Comments are recognized at the start of a line and, for ValueOfWithoutComments, anywhere in a value.
+// Note that values can then no longer contain any of these characters.
+func WithComments(chars ...rune) Option {
+	return func(o *options) {
+		o.comments = string(chars)
+	}
+}
+
+// WithStrictHeaders rejects section headers that aren't of the exact form "[name]", optionally
+// followed by a comment, and names containing '[' or ']', instead of doing a best effort parse of
+// headers like "[[[foo[][]".
+func WithStrictHeaders() Option {
+	return func(o *options) {
+		o.strictHeaders = true
+	}
+}
+
+// WithVariables sets the variables that section conditions are evaluated against, e.g. map[string]string{"os": runtime.GOOS}.
+// Without it, conditions are not evaluated and all sections are included. See ConditionPrefix.
+func WithVariables(vars map[string]string) Option {
+	return func(o *options) {
+		o.variables = vars
+	}
+}
+
+// Logger receives debug and warning events about reading and saving a configuration,
+// with alternating key and value arguments describing the event. *slog.Logger implements it.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+}
+
+// WithLogger sets the Logger that receives events, such as duplicate options, irregular section headers,
+// skipped sections and bytes written, while reading and later saving the Configuration.
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithSchema validates the Configuration against the schema after reading it, see Validate.
+// Reading fails with the *ValidationError if any value violates the schema's constraints.
+func WithSchema(schema *Schema) Option {
+	return func(o *options) {
+		o.schema = schema
+	}
+}
+
+// KeyWhitespace controls how whitespace inside option names is handled, see WithKeyWhitespace.
+type KeyWhitespace int
+
+const (
+	// KeyWhitespaceKeep keeps option names as they are, so "max  size" and "max size" are different options.
+	KeyWhitespaceKeep KeyWhitespace = iota
+	// KeyWhitespaceCollapse replaces runs of whitespace inside option names with a single space.
+	KeyWhitespaceCollapse
+	// KeyWhitespaceReject fails reading option names with whitespace other than single spaces.
+	KeyWhitespaceReject
+)
+
+// WithKeyWhitespace sets how whitespace inside option names is handled. With KeyWhitespaceCollapse,
+// names passed to methods such as ValueOf are collapsed as well, so "max  size" finds "max size".
+func WithKeyWhitespace(mode KeyWhitespace) Option {
+	return func(o *options) {
+		o.keyWhitespace = mode
+	}
+}
+
+// LongValuePolicy controls how values longer than the limit of WithMaxValueLength are handled.
+type LongValuePolicy int
+
+const (
+	// LongValueTruncate cuts values down to the limit, logging a warning, see WithLogger.
+	LongValueTruncate LongValuePolicy = iota
+	// LongValueReject fails reading an option with a value over the limit.
+	LongValueReject
+)
+
+// WithMaxValueLength limits the length of values to n bytes, protecting daemons from accidentally pasted
+// megabyte-long lines, which are read whatever their length. Longer values, including values joined from
+// continuation lines, are truncated, without splitting UTF-8 sequences, or rejected, depending on the policy.
+// Comments are not limited. See also Configuration.LargestValues.
+func WithMaxValueLength(n int, policy LongValuePolicy) Option {
+	return func(o *options) {
+		o.maxValueLen = n
+		o.longValues = policy
+	}
+}
+
+// HeaderIndent controls how whitespace before section headers is handled, see WithHeaderIndent.
+type HeaderIndent int
+
+const (
+	// HeaderIndentIgnore ignores whitespace before section headers.
+	HeaderIndentIgnore HeaderIndent = iota
+	// HeaderIndentReject fails reading section headers that don't start at the beginning of the line.
+	HeaderIndentReject
+	// HeaderIndentTree nests a section under the last section with a less indented header, naming it
+	// "<parent>.<name>". Each space or tab counts as one level of indentation. A section nested under
+	// a section excluded by its condition is excluded as well, see WithVariables.
+	HeaderIndentTree
+)
+
+// HeaderIndentSeparator separates the names of nested sections, see HeaderIndentTree.
+const HeaderIndentSeparator = "."
+
+// WithHeaderIndent sets how whitespace before section headers is handled. By default it is ignored.
+// With HeaderIndentTree,
+//
+//	[server]
+//	  [tls]
+//
+// reads the sections "server" and "server.tls", which are written back as unindented headers with their
+// full names.
+func WithHeaderIndent(mode HeaderIndent) Option {
+	return func(o *options) {
+		o.headerIndent = mode
+	}
+}
+
+// BinaryPolicy controls how NUL bytes and invalid UTF-8 in option lines are handled, see WithBinaryPolicy.
+type BinaryPolicy int
+
+const (
+	// BinaryPassThrough keeps values as they are read.
+	BinaryPassThrough BinaryPolicy = iota
+	// BinaryReject fails reading an option with NUL bytes or invalid UTF-8.
+	BinaryReject
+	// BinaryReplace replaces NUL bytes and invalid UTF-8 sequences in options with the Unicode replacement character.
+	BinaryReplace
+)
+
+// WithBinaryPolicy sets how NUL bytes and invalid UTF-8 in option lines are handled, so consumers expecting
+// text don't get binary data. By default they are passed through. Comments are not checked.
+func WithBinaryPolicy(policy BinaryPolicy) Option {
+	return func(o *options) {
+		o.binary = policy
+	}
+}
+
+// isBinary returns true if s has NUL bytes or invalid UTF-8
+func isBinary(s string) bool {
+	return strings.IndexByte(s, 0) != -1 || !utf8.ValidString(s)
+}
+
+// DuplicateSeparator separates the name of a repeated section from its index, see WithIndexedDuplicates.
+const DuplicateSeparator = "#"
+
+// WithIndexedDuplicates gives repeated sections unique names by appending their index to the name in
+// the header, so the second and third [foo] sections are named "foo#2" and "foo#3", and can be accessed
+// like any other section. HeaderName and SectionsWithHeader return the original names. The sections
+// are still written with their original header.
+func WithIndexedDuplicates() Option {
+	return func(o *options) {
+		o.duplicates = true
+	}
+}
+
+// WithGlobalName names the global section, which holds the options before the first section header.
+// The global section is then returned by Section and Sections for that name, e.g. Section("DEFAULT"),
+// and reading fails if a section header uses it. By default the global section has no name.
+func WithGlobalName(name string) Option {
+	return func(o *options) {
+		o.globalName = name
+	}
+}
+
+// Tracer starts a Span around reading, saving and reloading a configuration. It is meant to be implemented
+// on top of a tracing library such as OpenTelemetry, which this package doesn't depend on.
+type Tracer interface {
+	Start(name string) Span
+}
+
+// A Span times an operation, from Tracer.Start until End, and describes it with attributes such as
+// the file path, its size in bytes and the number of sections. End receives the error of the operation, if any.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	End(err error)
+}
+
+// WithTracer sets the Tracer that gets spans for reading the Configuration, and later saving and reloading it.
+func WithTracer(tracer Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+type nopTracer struct{}
+
+func (nopTracer) Start(name string) Span                     { return nopTracer{} }
+func (nopTracer) SetAttribute(key string, value interface{}) {}
+func (nopTracer) End(err error)                              {}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// log returns the Logger to send events to
+func (o *options) log() Logger {
+	if o == nil || o.logger == nil {
+		return nopLogger{}
+	}
+	return o.logger
+}
+
+// trace returns the Tracer to start spans with
+func (o *options) trace() Tracer {
+	if o == nil || o.tracer == nil {
+		return nopTracer{}
+	}
+	return o.tracer
+}
+
+// optionName returns the option name as it is stored, see name and WithKeyWhitespace.
+// Comments, which are stored as options, are left alone.
+func (o *options) optionName(name string) string {
+	name = o.name(name)
+	if o == nil || o.isComment(name) {
+		return name
+	}
+	if o.keyWhitespace == KeyWhitespaceCollapse {
+		name = collapseWhitespace(name)
+	}
+	if o.foldOptions {
+		name = strings.ToLower(name)
+	}
+	return name
+}
+
+// sectionName returns the section name as it is stored, see name and Dialect.FoldSectionNames
+func (o *options) sectionName(name string) string {
+	name = o.name(name)
+	if o != nil && o.foldSections {
+		name = strings.ToLower(name)
+	}
+	return name
+}
+
+// collapseWhitespace replaces all runs of whitespace in s with a single space
+func collapseWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// commentChar returns the character to start comments written by this package with
+func (o *options) commentChar() string {
+	if o == nil || o.comments == "" {
+		return "#"
+	}
+	_, size := utf8.DecodeRuneInString(o.comments)
+	return o.comments[:size]
+}
+
+// commentIndex returns the index of the first comment character in s, or -1 if there is none
+func (o *options) commentIndex(s string) int {
+	chars := "#"
+	if o != nil && o.comments != "" {
+		chars = o.comments
+	}
+	return strings.IndexAny(s, chars)
+}
+
+// isComment returns true if the trimmed line is a comment
+func (o *options) isComment(line string) bool {
+	return line != "" && o.commentIndex(line) == 0
+}
+
+// name returns the canonical form of a section or option name
+func (o *options) name(name string) string {
+	if o == nil {
+		return name
+	}
+	if o.normalize {
+		name = norm.NFC.String(name)
+	}
+	return name
+}
@@ -0,0 +1,446 @@
+package configparser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestUnicodeNormalization(t *testing.T) {
+	// "café" spelled with a precomposed é (NFC) and with e + combining acute accent (NFD)
+	nfc, nfd := "caf\u00e9", "cafe\u0301"
+	in := "[" + nfd + "]\n" + nfd + " = 1\n"
+
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conf.Section(nfc); err == nil {
+		t.Fatal("expected names to be kept as-is without normalization")
+	}
+
+	conf, err = Read(strings.NewReader(in), "", WithUnicodeNormalization())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{nfc, nfd} {
+		s, err := conf.Section(name)
+		if err != nil {
+			t.Fatalf("section %q: %s", name, err)
+		}
+		if s.Name() != nfc {
+			t.Fatalf("expected section name to be normalized to %q, got %q", nfc, s.Name())
+		}
+		if s.ValueOf(name) != "1" {
+			t.Fatalf("option %q: expected value 1, got %q", name, s.ValueOf(name))
+		}
+	}
+
+	s := conf.NewSection(nfd)
+	s.Add(nfd, "2")
+	sections, _ := conf.Sections(nfc)
+	if len(sections) != 2 || !s.Exists(nfc) || len(s.OptionNames()) != 1 {
+		t.Fatalf("expected names added through the API to be normalized as well")
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	// "café", "naïve" and "€" encoded in Windows-1252
+	in := "[caf\xe9]\nna\xefve = \x80 5\n"
+
+	conf, err := Read(strings.NewReader(in), "", WithEncoding(charmap.Windows1252))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := conf.Section("caf\u00e9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := s.ValueOf("na\u00efve"); v != "\u20ac 5" {
+		t.Fatalf("expected value %q, got %q", "\u20ac 5", v)
+	}
+}
+
+func TestStrictOptions(t *testing.T) {
+	in := `# comment
+a = 1
+
+[foo]
+  # indented comment
+b =
+c = 3
+`
+	if _, err := Read(strings.NewReader(in), "", WithStrictOptions()); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	in += "10.10.10.10\n"
+	if _, err := Read(strings.NewReader(in), ""); err != nil {
+		t.Fatalf("unexpected error without strict options %s", err)
+	}
+	_, err := Read(strings.NewReader(in), "/etc/foo.ini", WithStrictOptions())
+	if err == nil {
+		t.Fatal("expected error for option without delimiter")
+	}
+	if !strings.HasPrefix(err.Error(), "/etc/foo.ini:8: ") {
+		t.Fatalf("expected error to point at line 8, got %q", err)
+	}
+}
+
+func TestComments(t *testing.T) {
+	in := `; a comment
+[foo] ; about foo
+a = 1 ; one
+b = 2 # two
+; [bar]
+c = x[0]
+`
+	conf, err := Read(strings.NewReader(in), "", WithComments('#', ';'), WithStrictOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := conf.Section("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ValueOfWithoutComments("a") != "1" || s.ValueOfWithoutComments("b") != "2" {
+		t.Fatalf("expected comments to be stripped, got %q and %q", s.ValueOfWithoutComments("a"), s.ValueOfWithoutComments("b"))
+	}
+
+	if _, err := Read(strings.NewReader("a = 1\n; [bar]\n"), ""); err == nil {
+		t.Fatal("expected error for brackets after ';' when it doesn't start comments")
+	}
+}
+
+func TestStrictHeaders(t *testing.T) {
+	valid := []string{"[foo]", "  [foo bar]  ", "[foo] # comment", "[]"}
+	invalid := []string{"[[[foo[][]", "[foo", "[foo] bar", "[fo[o]]", "[foo]]"}
+
+	for _, in := range valid {
+		if _, err := Read(strings.NewReader(in), "", WithStrictHeaders()); err != nil {
+			t.Fatalf("header %q: unexpected error %s", in, err)
+		}
+	}
+	for _, in := range invalid {
+		if _, err := Read(strings.NewReader(in), "", WithStrictHeaders()); err == nil {
+			t.Fatalf("header %q: expected error", in)
+		}
+	}
+}
+
+type recordingLogger struct {
+	debug []string
+	warn  []string
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) { l.debug = append(l.debug, msg) }
+func (l *recordingLogger) Warn(msg string, args ...interface{})  { l.warn = append(l.warn, msg) }
+
+func TestLogger(t *testing.T) {
+	in := `[foo]
+a = 1
+a = 2
+[bar] trailing
+[baz] @if os=windows
+b = 3
+`
+	l := &recordingLogger{}
+	conf, err := Read(strings.NewReader(in), "test.ini", WithLogger(l), WithVariables(map[string]string{"os": "linux"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedWarn := []string{"duplicate option, the last value wins", "irregular section header"}
+	if !reflect.DeepEqual(l.warn, expectedWarn) {
+		t.Fatalf("expected warnings %v, got %v", expectedWarn, l.warn)
+	}
+	expectedDebug := []string{"skipping section excluded by condition", "read configuration"}
+	if !reflect.DeepEqual(l.debug, expectedDebug) {
+		t.Fatalf("expected debug events %v, got %v", expectedDebug, l.debug)
+	}
+
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	l.debug = nil
+	if err := Save(conf, filepath.Join(dir, "test.ini")); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.debug) != 1 || l.debug[0] != "saved configuration" {
+		t.Fatalf("expected a save event, got %v", l.debug)
+	}
+}
+
+func TestKeyWhitespace(t *testing.T) {
+	in := "[foo]\nmax  size = 1\n#  keep  comments\nmin\tsize\nmax size = 2\n"
+
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("foo")
+	if s.ValueOf("max  size") != "1" || s.ValueOf("max size") != "2" {
+		t.Fatal("expected options to be kept apart by default")
+	}
+
+	conf, err = Read(strings.NewReader(in), "", WithKeyWhitespace(KeyWhitespaceCollapse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ = conf.Section("foo")
+	if s.ValueOf("max size") != "2" || s.ValueOf("max \t size") != "2" || !s.Exists("min size") {
+		t.Fatalf("expected collapsed option names, got %v", s.OptionNames())
+	}
+	if !s.Exists("#  keep  comments") {
+		t.Fatalf("expected comments to be left alone, got %v", s.OptionNames())
+	}
+
+	_, err = Read(strings.NewReader(in), "test.ini", WithKeyWhitespace(KeyWhitespaceReject))
+	if err == nil || !strings.HasPrefix(err.Error(), "test.ini:2:") {
+		t.Fatalf("expected error for line 2, got %v", err)
+	}
+	if _, err := Read(strings.NewReader("[foo]\nmax size = 1\n"), "", WithKeyWhitespace(KeyWhitespaceReject)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGlobalName(t *testing.T) {
+	in := "a = 1\n[global]\nb = 2\n"
+
+	conf, err := Read(strings.NewReader(in), "", WithGlobalName("DEFAULT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.GlobalName() != "DEFAULT" {
+		t.Fatalf("expected global name DEFAULT, got %q", conf.GlobalName())
+	}
+	if v, err := conf.StringValue("DEFAULT", "a"); err != nil || v != "1" {
+		t.Fatalf("expected the global section by name, got %q, %v", v, err)
+	}
+	if v, err := conf.StringValue("global", "b"); err != nil || v != "2" {
+		t.Fatalf("expected [global] to be a regular section, got %q, %v", v, err)
+	}
+	if sections, err := conf.Sections("DEFAULT"); err != nil || len(sections) != 1 || sections[0] != conf.GlobalSection() {
+		t.Fatalf("expected the global section, got %v, %v", sections, err)
+	}
+	if conf.NumSections() != 1 || !strings.HasPrefix(conf.String(), "a"+Delimiter+"1\n[global]\n") {
+		t.Fatalf("expected the global section to stay without a header, got %q", conf.String())
+	}
+
+	if _, err := Read(strings.NewReader("[DEFAULT]\n"), "", WithGlobalName("DEFAULT")); err == nil {
+		t.Fatal("expected error for a section named like the global section")
+	}
+	if NewConfiguration().GlobalName() != "" {
+		t.Fatal("expected no global name by default")
+	}
+}
+
+type recordingTracer struct {
+	spans []*recordingSpan
+}
+
+type recordingSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (t *recordingTracer) Start(name string) Span {
+	s := &recordingSpan{name: name, attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, s)
+	return s
+}
+
+func (s *recordingSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *recordingSpan) End(err error)                              { s.err, s.ended = err, true }
+
+func TestTracer(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "test.ini")
+	in := "[foo]\na = 1\n[bar]\n"
+
+	tr := &recordingTracer{}
+	conf, err := Read(strings.NewReader(in), path, WithTracer(tr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, s := range tr.spans {
+		names = append(names, s.name)
+		if !s.ended || s.err != nil || s.attrs["file"] != path {
+			t.Fatalf("unexpected span %+v", s)
+		}
+	}
+	expected := []string{"configparser.Read", "configparser.Save", "configparser.Reload", "configparser.Read"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected spans %v, got %v", expected, names)
+	}
+	if tr.spans[0].attrs["bytes"] != int64(len(in)) || tr.spans[0].attrs["sections"] != 2 {
+		t.Fatalf("unexpected read attributes %v", tr.spans[0].attrs)
+	}
+	if tr.spans[1].attrs["bytes"] != int64(len(conf.String())) {
+		t.Fatalf("unexpected save attributes %v", tr.spans[1].attrs)
+	}
+
+	os.Remove(path)
+	if err := conf.Reload(); err == nil {
+		t.Fatal("expected error reloading a missing file")
+	}
+	if last := tr.spans[len(tr.spans)-1]; last.name != "configparser.Reload" || last.err == nil {
+		t.Fatalf("expected the error to be recorded, got %+v", last)
+	}
+}
+
+func TestIndexedDuplicates(t *testing.T) {
+	in := "[foo]\na = 1\n[bar]\n[foo]\na = 2\n[foo]\na = 3\n"
+
+	conf, err := Read(strings.NewReader(in), "", WithIndexedDuplicates())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, exp := range map[string]string{"foo": "1", "foo#2": "2", "foo#3": "3"} {
+		if v, err := conf.StringValue(name, "a"); err != nil || v != exp {
+			t.Fatalf("%s: expected %q, got %q, %v", name, exp, v, err)
+		}
+	}
+
+	var names []string
+	for _, s := range conf.SectionsWithHeader("foo") {
+		names = append(names, s.Name()+"/"+s.HeaderName())
+	}
+	if exp := []string{"foo/foo", "foo#2/foo", "foo#3/foo"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("expected sections %v, got %v", exp, names)
+	}
+
+	exp := "[foo]\na" + Delimiter + "1\n[bar]\n[foo]\na" + Delimiter + "2\n[foo]\na" + Delimiter + "3\n"
+	if conf.String() != exp {
+		t.Fatalf("expected the original headers to be written, got %q", conf.String())
+	}
+	if err := RoundTrips([]byte(in), WithIndexedDuplicates()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBinaryPolicy(t *testing.T) {
+	in := "[foo]\na = x\x00y\nb = caf\xe9\n# \xff comment\nc = ok\n"
+
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("foo")
+	if s.ValueOf("a") != "x\x00y" || s.ValueOf("b") != "caf\xe9" {
+		t.Fatal("expected values to be passed through by default")
+	}
+
+	_, err = Read(strings.NewReader(in), "test.ini", WithBinaryPolicy(BinaryReject))
+	if err == nil || !strings.HasPrefix(err.Error(), "test.ini:2:") {
+		t.Fatalf("expected error for line 2, got %v", err)
+	}
+
+	conf, err = Read(strings.NewReader(in), "", WithBinaryPolicy(BinaryReplace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ = conf.Section("foo")
+	if s.ValueOf("a") != "x�y" || s.ValueOf("b") != "caf�" || s.ValueOf("c") != "ok" {
+		t.Fatalf("expected replaced values, got %q and %q", s.ValueOf("a"), s.ValueOf("b"))
+	}
+}
+
+func TestHeaderIndent(t *testing.T) {
+	in := "[server]\na = 1\n  [tls]\n  cert = x\n    [ciphers] @if fips=yes\n    list = y\n  [http]\n[client]\n  [tls]\n  cert = z\n"
+
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conf.Section("tls"); err != nil {
+		t.Fatal("expected indentation to be ignored by default")
+	}
+
+	_, err = Read(strings.NewReader(in), "test.ini", WithHeaderIndent(HeaderIndentReject))
+	if err == nil || !strings.HasPrefix(err.Error(), "test.ini:3:") {
+		t.Fatalf("expected error for line 3, got %v", err)
+	}
+
+	conf, err = Read(strings.NewReader(in), "", WithHeaderIndent(HeaderIndentTree), WithVariables(map[string]string{"fips": "no"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	sections, _ := conf.Sections("")
+	for _, s := range sections {
+		names = append(names, s.Name())
+	}
+	if exp := []string{"server", "server.tls", "server.http", "client", "client.tls"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("expected sections %v, got %v", exp, names)
+	}
+	if v, _ := conf.StringValue("client.tls", "cert"); v != "z" {
+		t.Fatalf("expected nested option, got %q", v)
+	}
+
+	out := conf.String()
+	if !strings.Contains(out, "[server.tls]\n") {
+		t.Fatalf("expected nested sections to be written with their full name, got %q", out)
+	}
+	if err := RoundTrips([]byte(in), WithHeaderIndent(HeaderIndentTree)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMaxValueLength(t *testing.T) {
+	in := "[foo]\na = 12345\nb = 1234\n# comment longer than the limit\nc = abéé\n"
+
+	logger := &recordingLogger{}
+	conf, err := Read(strings.NewReader(in), "", WithMaxValueLength(4, LongValueTruncate), WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("foo")
+	if s.ValueOf("a") != "1234" || s.ValueOf("b") != "1234" || s.ValueOf("c") != "abé" {
+		t.Fatalf("expected truncated values, got %q", s.Options())
+	}
+	if len(logger.warn) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", logger.warn)
+	}
+
+	_, err = Read(strings.NewReader(in), "test.ini", WithMaxValueLength(4, LongValueReject))
+	if err == nil || !strings.HasPrefix(err.Error(), "test.ini:2:") {
+		t.Fatalf("expected error for line 2, got %v", err)
+	}
+
+	// lines longer than bufio.MaxScanTokenSize
+	conf, err = Read(strings.NewReader("[foo]\na = "+strings.Repeat("x", 1<<20)+"\n"), "", WithMaxValueLength(4, LongValueTruncate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := conf.Section("foo"); s.ValueOf("a") != "xxxx" {
+		t.Fatalf("expected truncated value, got %d bytes", len(s.ValueOf("a")))
+	}
+
+	// the limit applies to values joined from continuation lines
+	in = "[foo]\na = 12\n  34\n  56\nb = 1\n"
+	conf, err = Read(strings.NewReader(in), "", WithDialect(PythonConfigParser), WithMaxValueLength(4, LongValueTruncate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := conf.Section("foo"); s.ValueOf("a") != "12\n3" || s.ValueOf("b") != "1" {
+		t.Fatalf("expected truncated value, got %q", s.Options())
+	}
+	_, err = Read(strings.NewReader(in), "test.ini", WithDialect(PythonConfigParser), WithMaxValueLength(4, LongValueReject))
+	if err == nil || !strings.HasPrefix(err.Error(), "test.ini:3:") {
+		t.Fatalf("expected error for line 3, got %v", err)
+	}
+}
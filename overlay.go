@@ -0,0 +1,114 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// metaSource is the option metadata key recording where an option's value came from, see SetOptionMeta
+const metaSource = "source"
+
+// OverlayPath returns the path of the environment-specific overlay of a configuration file,
+// which has the environment name inserted before the extension, e.g. config.ini -> config.prod.ini.
+func OverlayPath(filePath, env string) string {
+	ext := filepath.Ext(filePath)
+	return strings.TrimSuffix(filePath, ext) + "." + env + ext
+}
+
+// ReadFileWithOverlay reads a base configuration file and merges the overlay for the given
+// environment on top of it, see OverlayPath. If env is empty or the overlay doesn't exist,
+// only the base file is read.
+//
+// Options in the overlay take precedence over the same options in the base file:
+//   - global options override global options
+//   - the n-th section with a given name overrides options in the n-th base section with that name
+//   - sections, or repeats of sections, that don't exist in the base file are added at the end
+//
+// The file path of the returned Configuration is that of the base file, so saving it writes the merged
+// result there, and Reload reads both files again. Overridden and added options record the overlay path as their source (see OptionMeta).
+// Comments and empty lines of the overlay are only kept in sections it adds.
+// WithSchema validates the merged result rather than either file, as an overlay usually only sets some of
+// the options. If the overlay sets constants to different values, see MarkConstant, a *ValidationError
+// listing them is returned.
+func ReadFileWithOverlay(filePath, env string, opts ...Option) (*Configuration, error) {
+	opts = append([]Option(nil), opts...)
+	p, schema := layerParser(opts)
+	base, err := p.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if env != "" {
+		overlayPath := OverlayPath(filePath, env)
+		overlay, err := p.ReadFile(overlayPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			markConstants(base, schema)
+			if violations := base.merge(overlay, overlayPath); len(violations) > 0 {
+				return nil, &ValidationError{FilePath: overlayPath, Violations: violations}
+			}
+		}
+	}
+
+	if err := applySchema(base, schema); err != nil {
+		return nil, err
+	}
+	base.reread = func() (*Configuration, error) { return ReadFileWithOverlay(filePath, env, opts...) }
+	return base, nil
+}
+
+// merge merges the options of src into c, see ReadFileWithOverlay, recording source as their origin.
+// Options that would override constants are left out and returned as violations.
+func (c *Configuration) merge(src *Configuration, source string) []Violation {
+	violations := mergeSection(c.global, src.global, source)
+
+	seen := make(map[string]int)
+	_, sections, _ := src.AllSections()
+	for _, s := range sections {
+		i := seen[s.fqn]
+		seen[s.fqn]++
+
+		existing, _ := c.Sections(s.fqn)
+		if i < len(existing) {
+			violations = append(violations, mergeSection(existing[i], s, source)...)
+			continue
+		}
+
+		added := s.copy()
+		for _, opt := range added.orderedOptions {
+			added.SetOptionMeta(opt, metaSource, source)
+		}
+		c.mutex.Lock()
+		c.insertSection(added)
+		c.mutex.Unlock()
+	}
+	return violations
+}
+
+// mergeSection adds all options of src to dst, overriding existing ones except for constants,
+// which are returned as violations if src sets them to a different value. Comments and empty lines are skipped.
+func mergeSection(dst, src *Section, source string) (violations []Violation) {
+	for _, opt := range src.OptionNames() {
+		if opt == "" || src.opts.isComment(opt) {
+			continue
+		}
+		if dst.IsConstant(opt) {
+			violations = append(violations, overrideViolation(dst, src, opt, source)...)
+			continue
+		}
+		dst.Add(opt, src.RawValueOf(opt))
+		if src.State(opt) == StateEmpty {
+			// Add makes options with an empty value bare
+			dst.SetValueFor(opt, "")
+		}
+		dst.SetOptionMeta(opt, metaSource, source)
+	}
+	return violations
+}
@@ -0,0 +1,120 @@
+package configparser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadFileWithOverlay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configparser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := filepath.Join(dir, "config.ini")
+	write := func(path, content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(base, `debug = false
+[db]
+host = localhost
+port = 5432
+[worker]
+threads = 1
+[worker]
+threads = 2
+`)
+	write(OverlayPath(base, "prod"), `debug = true
+[db]
+host = db.prod
+[worker]
+[worker]
+threads = 8
+[worker]
+threads = 16
+[metrics]
+enabled = true
+`)
+
+	if OverlayPath(base, "prod") != filepath.Join(dir, "config.prod.ini") {
+		t.Fatalf("unexpected overlay path %q", OverlayPath(base, "prod"))
+	}
+
+	conf, err := ReadFileWithOverlay(base, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "debug" + Delimiter + "true\n" +
+		"[db]\nhost" + Delimiter + "db.prod\nport" + Delimiter + "5432\n" +
+		"[worker]\nthreads" + Delimiter + "1\n" +
+		"[worker]\nthreads" + Delimiter + "8\n" +
+		"[worker]\nthreads" + Delimiter + "16\n" +
+		"[metrics]\nenabled" + Delimiter + "true\n"
+	if conf.String() != exp {
+		t.Fatalf("merged config mismatch\nexp %q\ngot %q", exp, conf.String())
+	}
+	if conf.FilePath() != base {
+		t.Fatalf("expected file path of the base file, got %q", conf.FilePath())
+	}
+	db, _ := conf.Section("db")
+	if db.OptionMeta("host", metaSource) != OverlayPath(base, "prod") || db.OptionMeta("port", metaSource) != "" {
+		t.Fatal("unexpected sources of merged options")
+	}
+
+	// Reload merges the overlay again
+	write(OverlayPath(base, "prod"), "[db]\nhost = db2.prod\n")
+	if err := conf.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := conf.StringValue("db", "host"); v != "db2.prod" || conf.GlobalSection().ValueOf("debug") != "false" {
+		t.Fatalf("expected the overlay to be merged again, got %q", conf.String())
+	}
+
+	conf, err = ReadFileWithOverlay(base, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := conf.StringValue("db", "host"); v != "localhost" {
+		t.Fatalf("expected only the base file to be read without an overlay, got %q", v)
+	}
+}
+
+func TestReadFileWithOverlaySchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configparser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := filepath.Join(dir, "config.ini")
+	write := func(path, content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	schema := &Schema{Sections: []SectionSchema{{Name: "server", ExactlyOneOf: [][]string{{"tcp_addr", "unix_socket"}}}}}
+	write(base, "[server]\ntcp_addr = :80\n")
+
+	// the overlay alone doesn't set either option
+	write(OverlayPath(base, "prod"), "[server]\n# production\ntimeout =\n\n")
+	conf, err := ReadFileWithOverlay(base, "prod", WithSchema(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "[server]\ntcp_addr" + Delimiter + ":80\ntimeout" + strings.TrimRight(Delimiter, " ") + "\n"
+	if conf.String() != exp {
+		t.Fatalf("merged config mismatch\nexp %q\ngot %q", exp, conf.String())
+	}
+
+	// the merged result sets both options
+	write(OverlayPath(base, "prod"), "[server]\nunix_socket = /run/app.sock\n")
+	if _, err := ReadFileWithOverlay(base, "prod", WithSchema(schema)); err == nil {
+		t.Fatal("expected the merged configuration to be validated")
+	}
+}
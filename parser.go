@@ -0,0 +1,109 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"io"
+	"os"
+	"path"
+	"sync"
+)
+
+// A Parser reads Configurations with a fixed set of Options, configured once with NewParser.
+// It can be reused for many files, also concurrently, and recycles its read buffers between them.
+type Parser struct {
+	opts    *options
+	buffers sync.Pool
+}
+
+// NewParser returns a Parser that reads with the given options.
+func NewParser(opts ...Option) *Parser {
+	return newParser(newOptions(opts))
+}
+
+func newParser(opts *options) *Parser {
+	p := &Parser{opts: opts}
+	p.buffers.New = func() interface{} {
+		buf := make([]byte, 4096)
+		return &buf
+	}
+	return p
+}
+
+// layerParser returns a Parser for the layers of a configuration, such as the overlay and the files of
+// ReadFiles, which reads with opts except for WithSchema, and the schema, which only applies to the
+// merged result, see applySchema.
+func layerParser(opts []Option) (*Parser, *Schema) {
+	o := newOptions(opts)
+	schema := o.schema
+	o.schema = nil
+	return newParser(o), schema
+}
+
+// applySchema validates c against the schema and marks the constants it describes, see WithSchema.
+// A nil schema is ignored.
+func applySchema(c *Configuration, schema *Schema) error {
+	if schema == nil {
+		return nil
+	}
+	if err := Validate(c, schema); err != nil {
+		return err
+	}
+	markConstants(c, schema)
+	return nil
+}
+
+// Read reads the given reader into a new Configuration.
+// filePath is set for any future persistency but is not used for reading.
+func (p *Parser) Read(fd io.Reader, filePath string) (*Configuration, error) {
+	return p.read(fd, filePath, nil)
+}
+
+// read reads the given reader into a new Configuration, recycling the sections of pool, see WithReuse
+func (p *Parser) read(fd io.Reader, filePath string, pool *sectionPool) (*Configuration, error) {
+	span := p.opts.trace().Start("configparser.Read")
+	span.SetAttribute("file", filePath)
+
+	buf := p.buffers.Get().(*[]byte)
+	defer p.buffers.Put(buf)
+	r := &countingReader{r: fd}
+	conf, err := read(r, filePath, p.opts, *buf, pool)
+
+	span.SetAttribute("bytes", r.n)
+	if conf != nil {
+		span.SetAttribute("sections", conf.NumSections())
+	}
+	span.End(err)
+	return conf, err
+}
+
+// ReadFile parses a specified configuration file and returns a Configuration instance.
+func (p *Parser) ReadFile(filePath string) (*Configuration, error) {
+	return p.readFile(filePath, nil)
+}
+
+// readFile reads the given file into a new Configuration, recycling the sections of pool, see WithReuse
+func (p *Parser) readFile(filePath string, pool *sectionPool) (*Configuration, error) {
+	filePath = path.Clean(filePath)
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return p.read(file, filePath, pool)
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,51 @@
+package configparser
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParser(t *testing.T) {
+	p := NewParser(WithComments('#', ';'), WithStrictOptions())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conf, err := p.Read(strings.NewReader("[foo]\na = 1 ; one\n"), "")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			s, err := conf.Section("foo")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if s.ValueOfWithoutComments("a") != "1" {
+				t.Errorf("expected the parser's comment characters to apply, got %q", s.ValueOfWithoutComments("a"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := p.Read(strings.NewReader("[foo]\nbare\n"), ""); err == nil {
+		t.Fatal("expected the parser's strict options to apply")
+	}
+	if _, err := p.ReadFile("does-not-exist.ini"); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
+
+func BenchmarkParser(b *testing.B) {
+	in := strings.Repeat("[section]\nopt1 = value1\nopt2 = value2\n# comment\n", 100)
+	p := NewParser()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Read(strings.NewReader(in), ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
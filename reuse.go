@@ -0,0 +1,112 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import "container/list"
+
+// WithReuse recycles the sections of a Configuration, with their option maps and slices, when it is reloaded,
+// see Reload, so that configurations reloaded every few seconds allocate less and put less pressure on the
+// garbage collector. Sections obtained before a reload must not be used after it, as they are cleared and
+// filled again by later reloads of the same Configuration. Sections are never recycled across configurations.
+func WithReuse() Option {
+	return func(o *options) {
+		o.reuse = true
+	}
+}
+
+// sectionPool holds the sections of a Configuration released by Reload, which its next reload fills again.
+// It is only used by one read at a time, see Reload.
+type sectionPool struct {
+	sections   []*Section            // cleared
+	sectionMap map[string]*list.List // empty
+}
+
+// section returns a recycled section, or a new one if the pool is nil or has none left
+func (p *sectionPool) section(fqn string, isGlobal bool, opts *options) *Section {
+	if p == nil || len(p.sections) == 0 {
+		return newSection(fqn, isGlobal, opts)
+	}
+	s := p.sections[len(p.sections)-1]
+	p.sections[len(p.sections)-1] = nil
+	p.sections = p.sections[:len(p.sections)-1]
+	s.fqn, s.isGlobal, s.opts = fqn, isGlobal, opts
+	return s
+}
+
+// addSection adds a non-global section with the given name to c, recycled from the pool if it has one
+func (p *sectionPool) addSection(c *Configuration, fqn string) *Section {
+	section := p.section(c.opts.sectionName(fqn), false, c.opts)
+	c.insertSection(section)
+	return section
+}
+
+// configuration returns a new Configuration like newConfiguration, with the global section and the map of
+// sections recycled from the pool if it has them
+func (p *sectionPool) configuration(filePath string, opts *options) *Configuration {
+	if p == nil || p.sectionMap == nil {
+		return newConfiguration(filePath, opts)
+	}
+	if opts == nil {
+		opts = &options{}
+	}
+	sections := p.sectionMap
+	p.sectionMap = nil
+	return &Configuration{
+		filePath: filePath,
+		global:   p.section(opts.sectionName(opts.globalName), true, opts),
+		sections: sections,
+		opts:     opts,
+	}
+}
+
+// release clears the global section, the sections of sections and the excluded sections, and puts them
+// into the pool
+func (p *sectionPool) release(global *Section, sections map[string]*list.List, excluded []excludedSection) {
+	if global != nil {
+		global.reset()
+		p.sections = append(p.sections, global)
+	}
+	for fqn, lst := range sections {
+		for e := lst.Front(); e != nil; e = e.Next() {
+			s := e.Value.(*Section)
+			s.reset()
+			p.sections = append(p.sections, s)
+		}
+		delete(sections, fqn)
+	}
+	for _, e := range excluded {
+		e.section.reset()
+		p.sections = append(p.sections, e.section)
+	}
+	if sections != nil {
+		p.sectionMap = sections
+	}
+}
+
+// reset clears the section, keeping the allocated maps and slices
+func (s *Section) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for k := range s.options {
+		delete(s.options, k)
+	}
+	for k := range s.bare {
+		delete(s.bare, k)
+	}
+	for k := range s.lines {
+		delete(s.lines, k)
+	}
+	for i := range s.rawLines {
+		s.rawLines[i] = ""
+	}
+	for i := range s.orderedOptions {
+		s.orderedOptions[i] = ""
+	}
+	s.rawLines = s.rawLines[:0]
+	s.orderedOptions = s.orderedOptions[:0]
+	s.fqn, s.header, s.condition, s.file, s.isGlobal, s.firstLine = "", "", "", "", false, 0
+	s.defaults, s.meta, s.optionMeta, s.opts = nil, nil, nil, nil
+}
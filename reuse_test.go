@@ -0,0 +1,123 @@
+package configparser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTempConfig(tb testing.TB, data string) (string, func()) {
+	dir, err := ioutil.TempDir("", "configparser")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	path := filepath.Join(dir, "test.ini")
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		tb.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestReloadReuse(t *testing.T) {
+	path, cleanup := writeTempConfig(t, "top = 1\n[a]\nx = 1\ny = 2\n[b]\nz = 3\n")
+	defer cleanup()
+
+	conf, err := ReadFile(path, WithReuse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("a")
+	s.SetMeta("k", "v")
+	for i := 0; i < 3; i++ {
+		if err := ioutil.WriteFile(path, []byte(fmt.Sprintf("[a]\nx = %d\n[c]\n", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := conf.Reload(); err != nil {
+			t.Fatal(err)
+		}
+		exp := fmt.Sprintf("[a]\nx%s%d\n[c]\n", Delimiter, i)
+		if conf.String() != exp {
+			t.Fatalf("expected %q, got %q", exp, conf.String())
+		}
+		a, _ := conf.Section("a")
+		if a.Meta("k") != "" || a.LineOf("x") != 2 || a.Exists("y") || len(a.RawLines()) != 2 {
+			t.Fatalf("expected a recycled section to be cleared, got %q", a.RawLines())
+		}
+		if _, err := conf.Section("b"); err == nil {
+			t.Fatal("expected section b to be removed")
+		}
+	}
+}
+
+func TestReloadReuseSeparate(t *testing.T) {
+	pathA, cleanupA := writeTempConfig(t, "[a]\nx = 1\n")
+	defer cleanupA()
+	pathB, cleanupB := writeTempConfig(t, "[b]\ny = 2\n")
+	defer cleanupB()
+
+	p := NewParser(WithReuse())
+	a, err := p.ReadFile(pathA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := p.ReadFile(pathB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, _ := a.Section("a")
+	if err := a.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := b.Section("b"); s == old || b.global == old {
+		t.Fatal("expected sections recycled by a not to be used by b")
+	}
+	if err := a.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := a.Section("a"); s != old && a.global != old {
+		t.Fatal("expected sections recycled by a to be reused by a")
+	}
+	if a.String() != "[a]\nx"+Delimiter+"1\n" || b.String() != "[b]\ny"+Delimiter+"2\n" {
+		t.Fatalf("unexpected configurations %q and %q", a.String(), b.String())
+	}
+}
+
+func BenchmarkReload(b *testing.B) {
+	var sb strings.Builder
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&sb, "[section%d]\n", i)
+		for j := 0; j < 20; j++ {
+			fmt.Fprintf(&sb, "option%d = value%d\n", j, j)
+		}
+	}
+	path, cleanup := writeTempConfig(b, sb.String())
+	defer cleanup()
+
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{"Default", nil},
+		{"Reuse", []Option{WithReuse()}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			conf, err := ReadFile(path, bench.opts...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := conf.Reload(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
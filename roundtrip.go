@@ -0,0 +1,104 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// RoundTrips checks that data, read with the given options, is written out by Write in a form that reads back
+// into the same sections as data, in the order they appear in data, with the same conditions, options, values
+// and states, and that writing the result again produces the same output. Tools can use it to verify that
+// editing a file with this package won't change the meaning of anything in it they don't understand. An error
+// describes the first difference.
+//
+// Write keeps comments and the order of options, but not the original formatting, and groups repeated sections
+// after the first one with the same name, which RoundTrips reports as a difference. Sections excluded by their
+// condition are compared as well. Use Section.RawLines to access the exact source text.
+func RoundTrips(data []byte, opts ...Option) error {
+	p := NewParser(opts...)
+	conf, err := p.Read(bytes.NewReader(data), "")
+	if err != nil {
+		return err
+	}
+
+	var written bytes.Buffer
+	if err := conf.Write(&written); err != nil {
+		return err
+	}
+	reread, err := p.Read(bytes.NewReader(written.Bytes()), "")
+	if err != nil {
+		return fmt.Errorf("reading written configuration: %s", err)
+	}
+	if err := compareConfigurations(conf, reread); err != nil {
+		return err
+	}
+
+	var rewritten bytes.Buffer
+	if err := reread.Write(&rewritten); err != nil {
+		return err
+	}
+	if !bytes.Equal(written.Bytes(), rewritten.Bytes()) {
+		return fmt.Errorf("writing the configuration again changed the output from %q to %q", written.String(), rewritten.String())
+	}
+	return nil
+}
+
+// compareConfigurations returns an error describing the first difference between the sections of a and b,
+// in the order they were read
+func compareConfigurations(a, b *Configuration) error {
+	if err := compareSections(a.global, b.global); err != nil {
+		return fmt.Errorf("global section: %s", err)
+	}
+	aSections, bSections := fileOrder(a), fileOrder(b)
+	if len(aSections) != len(bSections) {
+		return fmt.Errorf("read %d sections, but %d after writing", len(aSections), len(bSections))
+	}
+	for i := range aSections {
+		if aSections[i].Name() != bSections[i].Name() {
+			return fmt.Errorf("section %d: read [%s], but [%s] after writing", i+1, aSections[i].Name(), bSections[i].Name())
+		}
+		if aSections[i].Condition() != bSections[i].Condition() {
+			return fmt.Errorf("section %d [%s]: read condition %q, but %q after writing", i+1, aSections[i].Name(), aSections[i].Condition(), bSections[i].Condition())
+		}
+		if err := compareSections(aSections[i], bSections[i]); err != nil {
+			return fmt.Errorf("section %d [%s]: %s", i+1, aSections[i].Name(), err)
+		}
+	}
+	return nil
+}
+
+// fileOrder returns the non-global sections of c, including the sections excluded by their condition,
+// in the order they were read
+func fileOrder(c *Configuration) []*Section {
+	_, sections, _ := c.AllSections()
+	sections = append(sections, c.ExcludedSections()...)
+	inFileOrder(sections)
+	return sections
+}
+
+// compareSections returns an error describing the first difference between the options of a and b
+func compareSections(a, b *Section) error {
+	aNames, bNames := a.OptionNames(), b.OptionNames()
+	for i := 0; i < len(aNames) || i < len(bNames); i++ {
+		switch {
+		case i >= len(bNames):
+			return fmt.Errorf("option %q was lost after writing", aNames[i])
+		case i >= len(aNames):
+			return fmt.Errorf("unexpected option %q after writing", bNames[i])
+		case aNames[i] != bNames[i]:
+			return fmt.Errorf("option %d: read %q, but %q after writing", i+1, aNames[i], bNames[i])
+		}
+		opt := aNames[i]
+		if a.RawValueOf(opt) != b.RawValueOf(opt) {
+			return fmt.Errorf("option %q: read %q, but %q after writing", opt, a.RawValueOf(opt), b.RawValueOf(opt))
+		}
+		if a.State(opt) != b.State(opt) {
+			return fmt.Errorf("option %q: changed from state %d to %d after writing", opt, a.State(opt), b.State(opt))
+		}
+	}
+	return nil
+}
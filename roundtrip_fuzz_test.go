@@ -0,0 +1,21 @@
+//go:build go1.18
+
+package configparser
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzRoundTrips(f *testing.F) {
+	f.Add([]byte("# comment\n[foo]\na = 1\nbare\nempty =\n[bar]\n[foo]\nb = [x]\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if _, err := Read(bytes.NewReader(data), ""); err != nil {
+			return
+		}
+		// repeated sections keep their place only when indexed, see RoundTrips
+		if err := RoundTrips(data, WithIndexedDuplicates()); err != nil {
+			t.Fatalf("%q: %s", data, err)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package configparser
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestRoundTrips(t *testing.T) {
+	inputs := []string{
+		"",
+		"a = 1\n",
+		"no newline at the end",
+		"# comment\n\n[foo]\na=1\n  b  =  2  \nbare\nempty =\n",
+		"[foo]\na = 1\n[foo]\nc = 3\n[bar]\nb = 2\n",
+		"[foo]\na = 1\na = 2\n",
+		"[foo] # about foo\nvalue = [x] # y\nurl = http://host:80/?a=b&c=d\n",
+		"[foo]\r\na = 1\r\n\r\n",
+		"[a.b c]\n\tindented = tab\n",
+		"[win] @if os=windows\na = 1\n[other]\n",
+		"[a] @if os=windows\nx=1\n[b]\ny=2\n",
+		"[été]\nclé = über\n",
+	}
+	for _, in := range inputs {
+		if err := RoundTrips([]byte(in)); err != nil {
+			t.Fatalf("%q: unexpected error %s", in, err)
+		}
+	}
+
+	if err := RoundTrips([]byte("; [bar]\n"), WithComments('#', ';')); err != nil {
+		t.Fatalf("expected comment characters to apply to the reread output, got %s", err)
+	}
+
+	for _, opt := range []Option{WithVariables(map[string]string{"os": "windows"}), WithVariables(map[string]string{"os": "linux"})} {
+		if err := RoundTrips([]byte("[a] @if os=windows\nx=1\n[b]\ny=2\n"), opt); err != nil {
+			t.Fatalf("unexpected error for a conditional section %s", err)
+		}
+	}
+
+	// Write groups repeated sections
+	err := RoundTrips([]byte("[foo]\na = 1\n[bar]\nb = 2\n[foo]\nc = 3\n"))
+	if err == nil || !strings.Contains(err.Error(), "section 2: read [bar], but [foo] after writing") {
+		t.Fatalf("expected error for reordered sections, got %v", err)
+	}
+
+	if err := RoundTrips([]byte("[foo\n")); err == nil {
+		t.Fatal("expected error for invalid input")
+	}
+
+	// Write always produces UTF-8, which doesn't read back the same as Latin-1
+	err = RoundTrips([]byte("[foo]\na = caf\xe9\n"), WithEncoding(charmap.ISO8859_1))
+	if err == nil || !strings.Contains(err.Error(), `option "a"`) {
+		t.Fatalf("expected error for a value that changed, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Router matches inputs against regular expressions read from the sections of a Configuration, such as
+// the pattern option of Graphite's storage-schemas.conf:
+//
+//	[carbon]
+//	pattern = ^carbon\.
+//	retentions = 60:90d
+//
+//	[default]
+//	pattern = .*
+//	retentions = 60s:1d
+//
+// The first section whose pattern matches wins, see Route. A Router is immutable, later changes to the
+// Configuration are not reflected, and it can be used concurrently.
+type Router struct {
+	routes []route
+}
+
+type route struct {
+	match   func(string) bool
+	section *Section
+}
+
+// NewRouter returns a Router that compiles the value of the option of each section as a regular expression,
+// see regexp.Compile. Sections are tried in the order they were read, also for repeated sections, and for
+// configurations read from several files, see ReadFiles, in the order of the files. Sections added later
+// are tried last. Sections without the option, and the global section, are left out. An error is returned
+// if a value is not a valid regular expression.
+func NewRouter(c *Configuration, option string) (*Router, error) {
+	sections, _ := c.Sections("")
+	inFileOrder(sections)
+	r := &Router{}
+	for _, s := range sections {
+		if !s.Exists(option) {
+			continue
+		}
+		match, err := compileMatcher(s.ValueOf(option))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid %s in section %s: %v", s.LineOf(option), option, s.Name(), err)
+		}
+		r.routes = append(r.routes, route{match: match, section: s})
+	}
+	return r, nil
+}
+
+// Route returns the first section whose pattern matches the input, or nil if none does.
+func (r *Router) Route(input string) *Section {
+	for _, rt := range r.routes {
+		if rt.match(input) {
+			return rt.section
+		}
+	}
+	return nil
+}
+
+// Len returns the number of sections the Router matches against.
+func (r *Router) Len() int {
+	return len(r.routes)
+}
+
+// compileMatcher compiles the pattern to a function reporting whether it matches a string. Literals,
+// literal prefixes such as "^carbon\." and patterns matching anything, such as ".*", which are most of
+// the patterns found in practice, are matched without running the regular expression.
+func compileMatcher(pattern string) (func(string) bool, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	// regexp.Compile parses with the same flags
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return re.MatchString, nil
+	}
+	parsed = parsed.Simplify()
+
+	switch {
+	case parsed.Op == syntax.OpEmptyMatch || parsed.Op == syntax.OpStar:
+		return func(string) bool { return true }, nil
+	case isLiteral(parsed):
+		literal := string(parsed.Rune)
+		return func(s string) bool { return strings.Contains(s, literal) }, nil
+	case parsed.Op == syntax.OpConcat && len(parsed.Sub) == 2 && parsed.Sub[0].Op == syntax.OpBeginText && isLiteral(parsed.Sub[1]):
+		prefix := string(parsed.Sub[1].Rune)
+		return func(s string) bool { return strings.HasPrefix(s, prefix) }, nil
+	}
+	return re.MatchString, nil
+}
+
+// isLiteral returns true if re matches a case-sensitive literal string. Literals with the replacement
+// character are left out, since regular expressions match it against invalid UTF-8 as well.
+func isLiteral(re *syntax.Regexp) bool {
+	if re.Op != syntax.OpLiteral || re.Flags&syntax.FoldCase != 0 {
+		return false
+	}
+	for _, r := range re.Rune {
+		if r == utf8.RuneError {
+			return false
+		}
+	}
+	return true
+}
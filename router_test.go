@@ -0,0 +1,135 @@
+package configparser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	conf, err := Read(strings.NewReader(`pattern = ignored
+[carbon]
+pattern = ^carbon\.
+retentions = 60:90d
+[no pattern]
+retentions = 1:1d
+[stats]
+pattern = (?i)stats
+[literal]
+pattern = .count
+[default]
+pattern = .*
+retentions = 60s:1d
+`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRouter(conf, "pattern")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 4 {
+		t.Fatalf("expected 4 routes, got %d", r.Len())
+	}
+	for input, exp := range map[string]string{
+		"carbon.agents.cpu":  "carbon",
+		"app.carbon.cpu":     "default",
+		"STATS.requests":     "stats",
+		"app.requests.count": "literal",
+		"":                   "default",
+	} {
+		if s := r.Route(input); s == nil || s.Name() != exp {
+			t.Errorf("expected %q to route to %s, got %v", input, exp, s)
+		}
+	}
+
+	// repeated sections are tried in file order, not after the first section with the same name
+	repeated, _ := Read(strings.NewReader("[x]\npattern = ^abc\n[y]\npattern = ^ab\n[x]\npattern = ^a\n"), "")
+	r, err = NewRouter(repeated, "pattern")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := r.Route("abc"); s == nil || s.LineOf("pattern") != 2 {
+		t.Fatalf("expected the first [x] to win, got %v", s)
+	}
+	if s := r.Route("abd"); s == nil || s.Name() != "y" {
+		t.Fatalf("expected [y] to win over the second [x], got %v", s)
+	}
+	repeated.NewSection("added").Add("pattern", "^")
+	if r, _ := NewRouter(repeated, "pattern"); r.Route("zzz") == nil || r.Route("zzz").Name() != "added" || r.Route("abd").Name() != "y" {
+		t.Fatal("expected added sections to be tried last")
+	}
+
+	invalid, _ := Read(strings.NewReader("[a]\npattern = ^a\n[b]\npattern = [b\n"), "")
+	if _, err := NewRouter(invalid, "pattern"); err == nil || !strings.HasPrefix(err.Error(), "line 4: invalid pattern in section b:") {
+		t.Fatalf("expected error for an invalid pattern, got %v", err)
+	}
+	empty, _ := NewRouter(NewConfiguration(), "pattern")
+	if empty.Route("anything") != nil {
+		t.Fatal("expected no route")
+	}
+}
+
+func TestCompileMatcher(t *testing.T) {
+	patterns := []string{"", ".*", "a*", "abc", "^abc", "^abc$", "^ab[c-d]", "^a|b", "(?i)abc", "^(?i)abc", "a.c", "�", "(?m)^abc", "^"}
+	inputs := []string{"", "abc", "xabc", "ABC", "abcx", "a\nabc", "\xff", "ac"}
+	for _, pattern := range patterns {
+		match, err := compileMatcher(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		re := regexp.MustCompile(pattern)
+		for _, input := range inputs {
+			if got, exp := match(input), re.MatchString(input); got != exp {
+				t.Errorf("pattern %q, input %q: expected %v, got %v", pattern, input, exp, got)
+			}
+		}
+	}
+}
+
+func BenchmarkRouter(b *testing.B) {
+	var sb strings.Builder
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&sb, "[prefix%d]\npattern = ^service%d\\.\n", i, i)
+		fmt.Fprintf(&sb, "[regexp%d]\npattern = ^host[0-9]+\\.service%d\\.\n", i, i)
+	}
+	sb.WriteString("[default]\npattern = .*\n")
+	conf, err := Read(strings.NewReader(sb.String()), "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	inputs := []string{"service25.requests", "host12.service40.errors", "unmatched.metric"}
+
+	b.Run("Regexp", func(b *testing.B) {
+		sections, _ := conf.Sections("")
+		var patterns []*regexp.Regexp
+		for _, s := range sections {
+			patterns = append(patterns, regexp.MustCompile(s.ValueOf("pattern")))
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, input := range inputs {
+				for _, re := range patterns {
+					if re.MatchString(input) {
+						break
+					}
+				}
+			}
+		}
+	})
+	b.Run("Router", func(b *testing.B) {
+		r, err := NewRouter(conf, "pattern")
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, input := range inputs {
+				if r.Route(input) == nil {
+					b.Fatal("missing route")
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,279 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// maxSymlinks limits how many symlinks are followed when resolving the target of Save
+const maxSymlinks = 255
+
+// SaveOption configures how a Configuration is saved. See Save.
+type SaveOption func(*saveOptions)
+
+type saveOptions struct {
+	replaceSymlink bool
+	mode           os.FileMode
+	sync           bool
+	defaults       *Schema
+	provenance     bool
+	sections       func(a, b string) bool // see WithOrder
+	options        func(a, b string) bool
+}
+
+// ReplaceSymlink makes Save replace a symlink at the target path with a regular file,
+// instead of writing through to the file the symlink points to.
+func ReplaceSymlink() SaveOption {
+	return func(o *saveOptions) {
+		o.replaceSymlink = true
+	}
+}
+
+// WithFileMode sets the permissions of the saved file. By default, the permissions of the existing file
+// are kept, and new files are created with mode 0644.
+func WithFileMode(mode os.FileMode) SaveOption {
+	return func(o *saveOptions) {
+		o.mode = mode.Perm()
+	}
+}
+
+// WithSync makes Save flush the saved file, and the directory holding it, to stable storage before
+// returning, so the new configuration survives a crash or power loss of the host.
+func WithSync() SaveOption {
+	return func(o *saveOptions) {
+		o.sync = true
+	}
+}
+
+// WithCommentedDefaults makes Save write the options described by the schema that are not set as comments
+// holding their default value, e.g. "# port = 8080", after the options of their section, preceded by their
+// description. Sections of the schema that don't exist are written as comments as well. This way saved
+// files document all available options and can serve as templates. Comments written by an earlier save
+// are not repeated.
+func WithCommentedDefaults(schema *Schema) SaveOption {
+	return func(o *saveOptions) {
+		o.defaults = schema
+	}
+}
+
+// WithProvenance makes Save write a "# from: <source>" comment above each option, naming the file it came
+// from: the overlay that set it (see ReadFileWithOverlay), or else the file path of the Configuration.
+// This keeps a flattened file of a layered configuration auditable. Comments written by an earlier save
+// are replaced.
+func WithProvenance() SaveOption {
+	return func(o *saveOptions) {
+		o.provenance = true
+	}
+}
+
+// WithOrder makes Save write sections and options in the order of the less functions, e.g. sections ordered
+// by OrderedLess([]string{"main"}, LexicalLess) to write [main] first and the other sections alphabetically,
+// so generated files follow a convention. Either function may be nil to keep the current order.
+// The Configuration itself is left as it is, see SortSections and SortOptions for reordering it.
+func WithOrder(sections, options func(a, b string) bool) SaveOption {
+	return func(o *saveOptions) {
+		o.sections = sections
+		o.options = options
+	}
+}
+
+// write writes the Configuration to w as the options ask for
+func (o *saveOptions) write(c *Configuration, w io.Writer) error {
+	if o.sections != nil || o.options != nil {
+		c = c.sorted(o.sections, o.options)
+	}
+	source := ""
+	if o.provenance {
+		if source = c.FilePath(); source == "" {
+			source = "(unnamed)"
+		}
+	}
+	if o.defaults != nil {
+		return writeCommentedDefaults(c, w, o.defaults, source)
+	}
+	return c.write(w, source)
+}
+
+// Save the Configuration to file. Creates a backup (.bak) if file already exists.
+//
+// The file is replaced atomically: the Configuration is written to a temporary file in the same
+// directory, which is then renamed over the target. On Windows, the target is replaced with ReplaceFile
+// semantics, and operations failing because another process, such as a virus scanner, briefly holds
+// the file open are retried with backoff. If filePath is a symlink, the file it points to
+// is written and backed up, leaving the link in place, unless the ReplaceSymlink option is given.
+// The permissions and, where the platform and privileges allow it, the owner and group of an existing
+// file are preserved, see WithFileMode.
+func Save(c *Configuration, filePath string, opts ...SaveOption) error {
+	o := &saveOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	span := c.opts.trace().Start("configparser.Save")
+	span.SetAttribute("file", filePath)
+	n, err := save(c, filePath, o)
+	span.SetAttribute("bytes", n)
+	span.End(err)
+	return err
+}
+
+// save implements Save and returns the number of bytes written
+func save(c *Configuration, filePath string, o *saveOptions) (int64, error) {
+	if c.opts != nil && c.opts.target != nil {
+		return saveToTarget(c, filePath, o)
+	}
+
+	target := filePath
+	if !o.replaceSymlink {
+		var err error
+		target, err = resolveSymlinks(filePath)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	mode := os.FileMode(0644)
+	existing, err := os.Lstat(target)
+	if err == nil && existing.Mode().IsRegular() {
+		mode = existing.Mode().Perm()
+	} else if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	if o.mode != 0 {
+		mode = o.mode
+	}
+
+	tmp, n, err := writeTemp(c, target, mode, existing, o)
+	if err != nil {
+		return 0, err
+	}
+
+	if existing != nil {
+		err = backup(target)
+		if err != nil {
+			os.Remove(tmp)
+			return 0, err
+		}
+	}
+
+	err = replaceFile(tmp, target)
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	if o.sync {
+		err = syncDir(filepath.Dir(target))
+		if err != nil {
+			return 0, err
+		}
+	}
+	c.opts.log().Debug("saved configuration", "file", target, "bytes", n)
+	return n, nil
+}
+
+// Save saves the Configuration to its FilePath, see Save. The file path defaults to the one the
+// Configuration was read from, and can be changed with SetFilePath, e.g. for configurations read from stdin.
+func (c *Configuration) Save(opts ...SaveOption) error {
+	filePath := c.FilePath()
+	if filePath == "" {
+		return errors.New("configuration has no file path to save to")
+	}
+	return Save(c, filePath, opts...)
+}
+
+// resolveSymlinks follows the symlinks at path, returning the path of the file they eventually point to.
+// Unlike filepath.EvalSymlinks, the final target doesn't need to exist.
+func resolveSymlinks(path string) (string, error) {
+	for i := 0; i < maxSymlinks; i++ {
+		fi, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			return path, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return path, nil
+		}
+		link, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(path), link)
+		}
+		path = link
+	}
+	return "", fmt.Errorf("too many levels of symbolic links resolving %s", path)
+}
+
+// writeTemp writes the Configuration to a new temporary file next to target as o asks for, and returns
+// its path and size. The file gets the given mode, and the owner of the existing target, if there is one.
+// If o.sync is set, it is flushed to stable storage.
+func writeTemp(c *Configuration, target string, mode os.FileMode, existing os.FileInfo, o *saveOptions) (string, int64, error) {
+	f, err := ioutil.TempFile(filepath.Dir(target), "."+filepath.Base(target)+".tmp")
+	if err != nil {
+		return "", 0, err
+	}
+	w := &countingWriter{w: f}
+	err = o.write(c, w)
+	if err == nil && existing != nil {
+		err = chownLike(f, existing)
+	}
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if err == nil && o.sync {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", 0, err
+	}
+	return f.Name(), w.n, nil
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// backup keeps a copy of the existing file at path as path.bak.
+// It is hard linked where possible, so path keeps existing until it is replaced.
+func backup(path string) error {
+	bak := path + ".bak"
+	err := retry(func() error {
+		err := os.Remove(bak)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if os.Link(path, bak) == nil {
+		return nil
+	}
+	return retry(func() error {
+		return os.Rename(path, bak)
+	})
+}
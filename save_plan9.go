@@ -0,0 +1,29 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"os"
+)
+
+// chownLike is a no-op on Plan 9, which has no numeric file ownership
+func chownLike(f *os.File, fi os.FileInfo) error {
+	return nil
+}
+
+// replaceFile renames tmp over target
+func replaceFile(tmp, target string) error {
+	return os.Rename(tmp, target)
+}
+
+// retry calls fn once
+func retry(fn func() error) error {
+	return fn()
+}
+
+// syncDir is a no-op on Plan 9, where directories can't be flushed
+func syncDir(dir string) error {
+	return nil
+}
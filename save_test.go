@@ -0,0 +1,397 @@
+package configparser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "configparser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func readString(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSaveBackup(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "config.ini")
+	conf, _ := Read(strings.NewReader("a = 1\n"), path)
+	if err := Save(conf, path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+		t.Fatal("expected no backup of a new file")
+	}
+
+	conf.GlobalSection().Add("a", "2")
+	if err := Save(conf, path); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, path); got != "a"+Delimiter+"2\n" {
+		t.Fatalf("unexpected content %q", got)
+	}
+	if got := readString(t, path+".bak"); got != "a"+Delimiter+"1\n" {
+		t.Fatalf("unexpected backup content %q", got)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 2 {
+		t.Fatalf("expected no temporary files to be left behind, got %d files", len(files))
+	}
+}
+
+func TestSaveSymlink(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	target := filepath.Join(dir, "real.ini")
+	link := filepath.Join(dir, "link.ini")
+	if err := ioutil.WriteFile(target, []byte("a = 1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("real.ini", link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	conf, _ := Read(strings.NewReader("a = 2\n"), link)
+	if err := Save(conf, link); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("expected the symlink to be kept")
+	}
+	if got := readString(t, target); got != "a"+Delimiter+"2\n" {
+		t.Fatalf("expected the link target to be written, got %q", got)
+	}
+	if got := readString(t, target+".bak"); got != "a = 1\n" {
+		t.Fatalf("expected the link target to be backed up, got %q", got)
+	}
+	if fi, _ := os.Stat(target); fi.Mode().Perm() != 0600 {
+		t.Fatalf("expected the mode of the existing file to be kept, got %v", fi.Mode().Perm())
+	}
+
+	// dangling links are written through as well
+	dangling := filepath.Join(dir, "dangling.ini")
+	if err := os.Symlink("new.ini", dangling); err != nil {
+		t.Fatal(err)
+	}
+	if err := Save(conf, dangling); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, filepath.Join(dir, "new.ini")); got != "a"+Delimiter+"2\n" {
+		t.Fatalf("expected the dangling link target to be created, got %q", got)
+	}
+
+	conf.GlobalSection().Add("a", "3")
+	if err := Save(conf, link, ReplaceSymlink()); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(link); err != nil || !fi.Mode().IsRegular() {
+		t.Fatal("expected the symlink to be replaced by a regular file")
+	}
+	if got := readString(t, target); got != "a"+Delimiter+"2\n" {
+		t.Fatalf("expected the former link target to be untouched, got %q", got)
+	}
+}
+
+func TestSaveFileMode(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "config.ini")
+	conf, _ := Read(strings.NewReader("password = secret\n"), path)
+	if err := Save(conf, path); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0644 {
+		t.Fatalf("expected new file to have mode 0644, got %v", fi.Mode().Perm())
+	}
+	if err := Save(conf, path, WithFileMode(0600)); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0600 {
+		t.Fatalf("expected mode 0600, got %v", fi.Mode().Perm())
+	}
+	if err := Save(conf, path); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0600 {
+		t.Fatalf("expected mode 0600 to be preserved, got %v", fi.Mode().Perm())
+	}
+}
+
+func TestSaveSync(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "config.ini")
+	conf, _ := Read(strings.NewReader("a = 1\n"), path)
+	if err := Save(conf, path, WithSync()); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, path); got != "a"+Delimiter+"1\n" {
+		t.Fatalf("unexpected content %q", got)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "config.ini")
+	if err := ioutil.WriteFile(path, []byte("home = /a\n[paths]\ndata = %(home)s/data\n[paths:dev]\ndata = /tmp\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := ReadFile(path, WithInterpolation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.SetProfile("prod")
+
+	if err := ioutil.WriteFile(path, []byte("home = /b\n[paths]\ndata = %(home)s/data\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := conf.StringValue("paths", "data"); v != "/b/data" {
+		t.Fatalf("expected reloaded and interpolated value, got %q", v)
+	}
+	if conf.Profile() != "prod" || conf.NumSections() != 1 {
+		t.Fatal("unexpected state after reload")
+	}
+
+	if err := ioutil.WriteFile(path, []byte("[broken\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Reload(); err == nil {
+		t.Fatal("expected error reloading an invalid file")
+	}
+	if v, _ := conf.StringValue("paths", "data"); v != "/b/data" {
+		t.Fatalf("expected configuration to be unchanged after a failed reload, got %q", v)
+	}
+}
+
+func TestConfigurationSave(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	conf, _ := Read(strings.NewReader("a = 1\n"), "")
+	if err := conf.Save(); err == nil {
+		t.Fatal("expected error saving a configuration without file path")
+	}
+
+	path := filepath.Join(dir, "from-stdin.ini")
+	conf.SetFilePath(path)
+	if err := conf.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, path); got != "a"+Delimiter+"1\n" {
+		t.Fatalf("unexpected content %q", got)
+	}
+	if err := conf.Reload(); err == nil {
+		t.Fatal("expected error reloading a configuration that wasn't read from a file")
+	}
+
+	// the path saved to doesn't change the path reloaded from
+	conf, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copyPath := filepath.Join(dir, "copy.ini")
+	conf.SetFilePath(copyPath)
+	conf.GlobalSection().Add("a", "2")
+	if err := conf.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if v := conf.GlobalSection().ValueOf("a"); v != "1" || conf.SourcePath() != path || conf.FilePath() != copyPath {
+		t.Fatalf("expected to reload %s, got a = %q from %s", path, v, conf.SourcePath())
+	}
+}
+
+func TestSaveCommentedDefaults(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "app.ini")
+
+	schema := &Schema{
+		Sections: []SectionSchema{
+			{
+				Name: "server",
+				Options: []OptionSchema{
+					{Name: "host", Default: "localhost", Description: "the interface to listen on"},
+					{Name: "port", Default: "8080"},
+					{Name: "tls_cert"},
+				},
+			},
+			{
+				Name:        "log",
+				Description: "Logging",
+				Options:     []OptionSchema{{Name: "level", Default: "info"}},
+			},
+		},
+	}
+	conf, err := Read(strings.NewReader("[server]\nport = 80\n"), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := strings.NewReplacer(" = ", Delimiter, " =\n", strings.TrimRight(Delimiter, " ")+"\n").Replace(`[server]
+port = 80
+# the interface to listen on
+# host = localhost
+# tls_cert =
+# [log]
+# Logging
+# level = info
+`)
+	for i := 0; i < 2; i++ {
+		if err := Save(conf, path, WithCommentedDefaults(schema)); err != nil {
+			t.Fatal(err)
+		}
+		if got := readString(t, path); got != exp {
+			t.Fatalf("save %d: expected:\n%s\ngot:\n%s", i+1, exp, got)
+		}
+		// saving what was read back doesn't repeat the comments
+		conf, err = ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// comments are split at the delimiters of the dialect when looking for earlier ones
+	defer func(delimiter string) { Delimiter = delimiter }(Delimiter)
+	Delimiter = ": "
+	colon := WithDialect(Dialect{Delimiters: ":", Comments: "#"})
+	schema = &Schema{Sections: []SectionSchema{{Name: "log", Options: []OptionSchema{{Name: "level", Default: "info"}}}}}
+	conf, err = Read(strings.NewReader("[log]\n"), path, colon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp = "[log]\n# level: info\n"
+	for i := 0; i < 2; i++ {
+		if err := Save(conf, path, WithCommentedDefaults(schema)); err != nil {
+			t.Fatal(err)
+		}
+		if got := readString(t, path); got != exp {
+			t.Fatalf("save %d: expected:\n%s\ngot:\n%s", i+1, exp, got)
+		}
+		conf, err = ReadFile(path, colon)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSaveProvenance(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	base := filepath.Join(dir, "app.ini")
+	overlay := OverlayPath(base, "prod")
+	if err := ioutil.WriteFile(base, []byte("# base\n[db]\nhost = localhost\nport = 5432\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(overlay, []byte("[db]\nhost = db.prod\n[cache]\nsize = 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := ReadFileWithOverlay(base, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "effective.ini")
+	exp := strings.NewReplacer(" = ", Delimiter, "BASE", base, "OVERLAY", overlay).Replace(`# base
+[db]
+# from: OVERLAY
+host = db.prod
+# from: BASE
+port = 5432
+[cache]
+# from: OVERLAY
+size = 10
+`)
+	if err := Save(conf, path, WithProvenance()); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, path); got != exp {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, got)
+	}
+
+	// saving the saved file again replaces the comments instead of repeating them
+	conf, err = ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.SetFilePath(base)
+	if err := Save(conf, path, WithProvenance()); err != nil {
+		t.Fatal(err)
+	}
+	exp = strings.Replace(exp, overlay, base, -1)
+	if got := readString(t, path); got != exp {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, got)
+	}
+}
+
+func TestSaveSplit(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	conf, err := Read(strings.NewReader("[web]\nport"+Delimiter+"80\n[db]\nhost"+Delimiter+"localhost\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SaveSplit(conf, out, func(s *Section) string {
+		if s.Name() == "" {
+			return ""
+		}
+		return s.Name() + ".conf"
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, filepath.Join(out, "db.conf")); got != "[db]\nhost"+Delimiter+"localhost\n" {
+		t.Fatalf("unexpected db.conf %q", got)
+	}
+
+	for _, name := range []string{"../escape.conf", "sub/../../escape.conf", "..", filepath.Join(dir, "escape.conf")} {
+		err := SaveSplit(conf, out, func(s *Section) string {
+			switch s.Name() {
+			case "web":
+				return "web2.conf"
+			case "db":
+				return name
+			}
+			return ""
+		})
+		if err == nil {
+			t.Fatalf("expected %q to be rejected", name)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "escape.conf")); !os.IsNotExist(err) {
+			t.Fatalf("expected nothing to be written outside the directory for %q", name)
+		}
+		if _, err := os.Stat(filepath.Join(out, "web2.conf")); !os.IsNotExist(err) {
+			t.Fatalf("expected nothing to be written for %q", name)
+		}
+	}
+}
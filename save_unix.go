@@ -0,0 +1,57 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package configparser
+
+import (
+	"os"
+	"syscall"
+)
+
+// chownLike gives f the owner and group of the file described by fi.
+// Lacking the privileges to do so is not an error: the file then keeps the owner of the current process.
+func chownLike(f *os.File, fi os.FileInfo) error {
+	want, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	current, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if have, ok := current.Sys().(*syscall.Stat_t); ok && have.Uid == want.Uid && have.Gid == want.Gid {
+		return nil
+	}
+	err = f.Chown(int(want.Uid), int(want.Gid))
+	if os.IsPermission(err) {
+		return nil
+	}
+	return err
+}
+
+// replaceFile atomically renames tmp over target
+func replaceFile(tmp, target string) error {
+	return os.Rename(tmp, target)
+}
+
+// retry calls fn once, files can't be locked against renames here
+func retry(fn func() error) error {
+	return fn()
+}
+
+// syncDir flushes the directory entries of dir to stable storage
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	errorAccessDenied     syscall.Errno = 5
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+
+	replacefileIgnoreMergeErrors = 0x2
+
+	// maxRetries and retryDelay bound how long operations on a file held open by another process are retried,
+	// the delay doubles after every attempt
+	maxRetries = 8
+	retryDelay = 10 * time.Millisecond
+)
+
+var procReplaceFileW = syscall.NewLazyDLL("kernel32.dll").NewProc("ReplaceFileW")
+
+// chownLike is a no-op on Windows, ReplaceFile preserves the ACLs of the replaced file instead
+func chownLike(f *os.File, fi os.FileInfo) error {
+	return nil
+}
+
+// replaceFile replaces target with tmp using ReplaceFile, which keeps the attributes and ACLs of target,
+// or renames tmp to target if it doesn't exist yet
+func replaceFile(tmp, target string) error {
+	return retry(func() error {
+		if _, err := os.Lstat(target); os.IsNotExist(err) {
+			return os.Rename(tmp, target)
+		}
+		replaced, err := syscall.UTF16PtrFromString(target)
+		if err != nil {
+			return err
+		}
+		replacement, err := syscall.UTF16PtrFromString(tmp)
+		if err != nil {
+			return err
+		}
+		r, _, err := procReplaceFileW.Call(uintptr(unsafe.Pointer(replaced)), uintptr(unsafe.Pointer(replacement)), 0, replacefileIgnoreMergeErrors, 0, 0)
+		if r == 0 {
+			return &os.LinkError{Op: "replace", Old: tmp, New: target, Err: err}
+		}
+		return nil
+	})
+}
+
+// retry calls fn until it succeeds or fails for another reason than the file being in use by another process
+func retry(fn func() error) error {
+	delay := retryDelay
+	for i := 0; ; i++ {
+		err := fn()
+		if err == nil || i == maxRetries || !isSharingViolation(err) {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// isSharingViolation returns true if err is caused by another process holding the file open.
+// ReplaceFile reports a replaced file that a virus scanner still has open as access denied, so that is
+// retried for ReplaceFile only, everywhere else access denied is a real permission error and fails at once.
+func isSharingViolation(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	if errno == errorAccessDenied {
+		var linkErr *os.LinkError
+		return errors.As(err, &linkErr) && linkErr.Op == "replace"
+	}
+	return errno == errorSharingViolation || errno == errorLockViolation
+}
+
+// syncDir is a no-op on Windows, where directories can't be flushed and ReplaceFile is already durable
+func syncDir(dir string) error {
+	return nil
+}
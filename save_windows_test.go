@@ -0,0 +1,51 @@
+package configparser
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestRetry(t *testing.T) {
+	attempts := 0
+	err := retry(func() error {
+		attempts++
+		if attempts < 3 {
+			return &os.PathError{Op: "rename", Path: "config.ini", Err: errorSharingViolation}
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Fatalf("expected success after 3 attempts, got %v after %d", err, attempts)
+	}
+
+	attempts = 0
+	err = retry(func() error {
+		attempts++
+		return errors.New("boom")
+	})
+	if err == nil || attempts != 1 {
+		t.Fatalf("expected other errors not to be retried, got %v after %d attempts", err, attempts)
+	}
+
+	attempts = 0
+	err = retry(func() error {
+		attempts++
+		return &os.PathError{Op: "open", Path: "config.ini", Err: errorAccessDenied}
+	})
+	if err == nil || attempts != 1 {
+		t.Fatalf("expected access denied not to be retried, got %v after %d attempts", err, attempts)
+	}
+
+	attempts = 0
+	err = retry(func() error {
+		attempts++
+		if attempts < 2 {
+			return &os.LinkError{Op: "replace", Old: "config.ini.tmp", New: "config.ini", Err: errorAccessDenied}
+		}
+		return nil
+	})
+	if err != nil || attempts != 2 {
+		t.Fatalf("expected access denied to be retried for ReplaceFile, got %v after %d attempts", err, attempts)
+	}
+}
@@ -0,0 +1,513 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sourceDefault is the source recorded for options filled in from a Schema default, see ApplyDefaults
+const sourceDefault = "default"
+
+// Schema describes the sections and options an application expects in its configuration.
+type Schema struct {
+	Sections []SectionSchema
+	// Checks are run by Validate with the whole Configuration, for rules that span sections.
+	Checks []Check
+}
+
+// A Check validates a whole Configuration, e.g. that every [route-*] section references an existing
+// [destination-*] section, and returns the violations it found.
+type Check func(conf *Configuration) []Violation
+
+// SectionSchema describes a section and the options it may contain.
+type SectionSchema struct {
+	// Name is the name of the section. The name "" describes the global section, as does the name set
+	// with WithGlobalName.
+	Name        string
+	Description string
+	Options     []OptionSchema
+	// ExactlyOneOf lists groups of options of which exactly one must be set, e.g. {"unix_socket", "tcp_addr"}.
+	ExactlyOneOf [][]string
+	// AtMostOneOf lists groups of mutually exclusive options.
+	AtMostOneOf [][]string
+	// Requires maps an option to the options that must also be set if it is, e.g. "tls_cert": {"tls_key"}.
+	Requires map[string][]string
+	// Constant makes all options of the section constant when read WithSchema, see Section.MarkAllConstant.
+	Constant bool
+}
+
+// OptionSchema describes an option.
+type OptionSchema struct {
+	Name        string
+	Description string
+	// Type is a free-form description of the type of the value, e.g. "int" or "duration".
+	Type string
+	// Default is the value of the option if it is missing. Options with an empty Default have no default,
+	// unless HasDefault is set.
+	Default string
+	// HasDefault makes an empty Default the default value of the option.
+	HasDefault bool
+	// Pattern is a regular expression the whole value must match, if not empty, e.g. `[^:]+:[0-9]+`.
+	Pattern string
+	// PatternDescription describes Pattern in validation errors, e.g. "host:port".
+	PatternDescription string
+	// Min and Max are the inclusive bounds of a numeric value, if not nil.
+	// Step requires the value to be a multiple of it, counting from Min if it is set or 0 otherwise.
+	// Values are parsed as decimal integers if Type is "int", as in the code generated by configgen, and as
+	// floats otherwise.
+	Min, Max, Step *float64
+	// RemovedIn is the version of the application that stopped using the option, if it did, see StaleOptions.
+	RemovedIn string
+	// ReplacedBy is the name of the option that took over from a removed option, if any.
+	ReplacedBy string
+	// Constant makes the option constant when read WithSchema, see Section.MarkConstant.
+	Constant bool
+}
+
+// Float returns a pointer to f, for use as an OptionSchema Min, Max or Step.
+func Float(f float64) *float64 {
+	return &f
+}
+
+// section returns the schema of the section with the given name, or nil if there is none
+func (schema *Schema) section(name string) *SectionSchema {
+	if schema == nil {
+		return nil
+	}
+	for i := range schema.Sections {
+		if schema.Sections[i].Name == name {
+			return &schema.Sections[i]
+		}
+	}
+	return nil
+}
+
+// sectionOf returns the schema of s, or nil if there is none, see SectionSchema.Name
+func (schema *Schema) sectionOf(s *Section) *SectionSchema {
+	if s.isGlobal {
+		if ss := schema.section(""); ss != nil {
+			return ss
+		}
+	}
+	return schema.section(s.Name())
+}
+
+// sections returns the sections of conf the SectionSchema describes, see SectionSchema.Name
+func (ss *SectionSchema) sections(conf *Configuration) ([]*Section, error) {
+	if ss.Name == "" {
+		return []*Section{conf.GlobalSection()}, nil
+	}
+	return conf.Sections(ss.Name)
+}
+
+// hasDefault returns true if the option has a default value, see HasDefault
+func (opt *OptionSchema) hasDefault() bool {
+	return opt.Default != "" || opt.HasDefault
+}
+
+// option returns the schema of the option with the given name, or nil if there is none
+func (ss *SectionSchema) option(name string) *OptionSchema {
+	if ss == nil {
+		return nil
+	}
+	for i := range ss.Options {
+		if ss.Options[i].Name == name {
+			return &ss.Options[i]
+		}
+	}
+	return nil
+}
+
+// ApplyDefaults fills in the defaults of the schema for all options missing from conf.
+// Sections described by the schema that don't exist are created. If a section is repeated,
+// the defaults are applied to all of them. Filled in options are marked as defaulted, see IsDefaulted.
+func ApplyDefaults(conf *Configuration, schema *Schema) {
+	for _, ss := range schema.Sections {
+		sections, err := ss.sections(conf)
+		if err != nil {
+			sections = []*Section{conf.NewSection(ss.Name)}
+		}
+		for _, s := range sections {
+			for _, opt := range ss.Options {
+				if !opt.hasDefault() || s.Exists(opt.Name) {
+					continue
+				}
+				s.Add(opt.Name, opt.Default)
+				if opt.Default == "" {
+					// Add makes options with an empty value bare
+					s.SetValueFor(opt.Name, "")
+				}
+				s.SetOptionMeta(opt.Name, metaSource, sourceDefault)
+			}
+		}
+	}
+}
+
+// IsDefaulted returns true if the option's value was filled in from a schema default by ApplyDefaults.
+func (s *Section) IsDefaulted(option string) bool {
+	return s.OptionMeta(option, metaSource) == sourceDefault
+}
+
+// A Violation is a value that doesn't satisfy the constraints of its OptionSchema.
+type Violation struct {
+	Section string
+	Option  string
+	Value   string
+	Message string
+	// Line is the line number the option was read from, or 0 if it is unknown.
+	Line int
+	// File is the file the option was read from if it isn't the FilePath of the ValidationError, e.g. in a
+	// configuration read with ReadFiles, or "" otherwise.
+	File string
+}
+
+func (v Violation) String() string {
+	if v.Option == "" {
+		return fmt.Sprintf("[%s]: %s", v.Section, v.Message)
+	}
+	return fmt.Sprintf("[%s] %s = %q: %s", v.Section, v.Option, v.Value, v.Message)
+}
+
+// ValidationError is returned by Validate with all the violations found in a Configuration.
+type ValidationError struct {
+	FilePath   string
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		filePath := e.FilePath
+		if v.File != "" {
+			filePath = v.File
+		}
+		switch {
+		case v.Line > 0:
+			msgs[i] = parseErrorf(filePath, v.Line, "%s", v).Error()
+		case filePath != "":
+			msgs[i] = filePath + ": " + v.String()
+		default:
+			msgs[i] = v.String()
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate checks the options in conf against the constraints of the schema, and runs its Checks,
+// and returns a *ValidationError listing all the violations. Options and sections that are not in the schema,
+// or missing from conf, are not checked. An error is also returned if the schema itself is invalid.
+func Validate(conf *Configuration, schema *Schema) error {
+	var violations []Violation
+	for _, ss := range schema.Sections {
+		sections, err := ss.sections(conf)
+		if err != nil {
+			continue
+		}
+		for _, opt := range ss.Options {
+			check, err := opt.checker()
+			if err != nil {
+				return err
+			}
+			for _, s := range sections {
+				if !s.Exists(opt.Name) {
+					continue
+				}
+				value := s.ValueOf(opt.Name)
+				if msg := check(value); msg != "" {
+					violations = append(violations, Violation{
+						Section: s.Name(), Option: opt.Name, Value: value, Message: msg,
+						Line: s.LineOf(opt.Name), File: fileOf(conf, s, opt.Name),
+					})
+				}
+			}
+		}
+		for _, s := range sections {
+			groups := ss.checkGroups(s)
+			for i := range groups {
+				groups[i].File = fileOf(conf, s, groups[i].Option)
+			}
+			violations = append(violations, groups...)
+		}
+	}
+	for _, check := range schema.Checks {
+		violations = append(violations, check(conf)...)
+	}
+	if len(violations) > 0 {
+		return &ValidationError{FilePath: conf.FilePath(), Violations: violations}
+	}
+	return nil
+}
+
+// fileOf returns the file the option of s was read from, or the file of s if the option is "", if it isn't
+// the file of conf, as in configurations merged from several files, or "" otherwise
+func fileOf(conf *Configuration, s *Section, option string) string {
+	file := ""
+	if option != "" {
+		file = s.OptionMeta(option, metaSource)
+	}
+	if file == "" || file == sourceDefault {
+		file = s.File()
+	}
+	if file == conf.FilePath() {
+		return ""
+	}
+	return file
+}
+
+// checker returns a function that describes why a value violates the constraints of the option,
+// or returns "" if it doesn't
+func (opt *OptionSchema) checker() (func(value string) string, error) {
+	var pattern *regexp.Regexp
+	if opt.Pattern != "" {
+		var err error
+		pattern, err = regexp.Compile("^(?:" + opt.Pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for option %q: %s", opt.Name, err)
+		}
+	}
+
+	return func(value string) string {
+		if pattern != nil && !pattern.MatchString(value) {
+			if opt.PatternDescription != "" {
+				return "must be " + opt.PatternDescription
+			}
+			return "must match " + opt.Pattern
+		}
+		if opt.Min != nil || opt.Max != nil || opt.Step != nil {
+			return opt.checkRange(value)
+		}
+		return ""
+	}, nil
+}
+
+// checkRange describes why value is not a number within the Min, Max and Step of the option,
+// or returns "" if it is
+func (opt *OptionSchema) checkRange(value string) string {
+	var f float64
+	if opt.Type == "int" {
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "must be an integer"
+		}
+		f = float64(i)
+	} else {
+		var err error
+		f, err = strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) {
+			return "must be a number"
+		}
+	}
+
+	if opt.Min != nil && f < *opt.Min {
+		return "must be at least " + formatFloat(*opt.Min)
+	}
+	if opt.Max != nil && f > *opt.Max {
+		return "must be at most " + formatFloat(*opt.Max)
+	}
+	if opt.Step != nil && *opt.Step > 0 {
+		base := 0.0
+		if opt.Min != nil {
+			base = *opt.Min
+		}
+		n := (f - base) / *opt.Step
+		if math.Abs(n-math.Round(n)) > 1e-9 {
+			if base != 0 {
+				return "must be " + formatFloat(base) + " plus a multiple of " + formatFloat(*opt.Step)
+			}
+			return "must be a multiple of " + formatFloat(*opt.Step)
+		}
+	}
+	return ""
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// checkGroups returns the violations of the ExactlyOneOf, AtMostOneOf and Requires rules in s
+func (ss *SectionSchema) checkGroups(s *Section) []Violation {
+	var violations []Violation
+	set := func(names []string) []string {
+		var found []string
+		for _, name := range names {
+			if s.Exists(name) {
+				found = append(found, name)
+			}
+		}
+		return found
+	}
+
+	for _, group := range ss.ExactlyOneOf {
+		found := set(group)
+		if len(found) == 1 {
+			continue
+		}
+		msg := "exactly one of " + strings.Join(group, ", ") + " must be set"
+		if len(found) == 0 {
+			msg += ", found none"
+		} else {
+			msg += ", found " + strings.Join(found, ", ")
+		}
+		violations = append(violations, Violation{Section: s.Name(), Message: msg})
+	}
+	for _, group := range ss.AtMostOneOf {
+		if found := set(group); len(found) > 1 {
+			msg := "at most one of " + strings.Join(group, ", ") + " may be set, found " + strings.Join(found, ", ")
+			violations = append(violations, Violation{Section: s.Name(), Message: msg})
+		}
+	}
+
+	// sort the options for stable errors, as Requires is a map
+	var options []string
+	for opt := range ss.Requires {
+		options = append(options, opt)
+	}
+	sort.Strings(options)
+	for _, opt := range options {
+		if !s.Exists(opt) {
+			continue
+		}
+		for _, required := range ss.Requires[opt] {
+			if !s.Exists(required) {
+				violations = append(violations, Violation{
+					Section: s.Name(), Option: opt, Value: s.ValueOf(opt),
+					Message: "requires " + required + " to be set", Line: s.LineOf(opt),
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// A StaleOption is an option found in a Configuration that its schema marks as removed.
+type StaleOption struct {
+	Section    string
+	Option     string
+	RemovedIn  string
+	ReplacedBy string
+	// Line is the line number the option was read from, or 0 if it is unknown.
+	Line int
+	// File is the file the option was read from if it isn't the FilePath of the ValidationError, e.g. in a
+	// configuration read with ReadFiles, or "" otherwise.
+	File string
+}
+
+func (so StaleOption) String() string {
+	msg := fmt.Sprintf("[%s] %s was removed in %s", so.Section, so.Option, so.RemovedIn)
+	if so.ReplacedBy != "" {
+		msg += ", use " + so.ReplacedBy + " instead"
+	}
+	if so.Line > 0 {
+		msg = fmt.Sprintf("line %d: %s", so.Line, msg)
+	}
+	return msg
+}
+
+// StaleOptions returns the options set in conf that the schema marks as removed with RemovedIn,
+// in schema order, so applications can warn about them at startup or when upgrading.
+func StaleOptions(conf *Configuration, schema *Schema) []StaleOption {
+	var stale []StaleOption
+	for _, ss := range schema.Sections {
+		sections, err := ss.sections(conf)
+		if err != nil {
+			continue
+		}
+		for _, opt := range ss.Options {
+			if opt.RemovedIn == "" {
+				continue
+			}
+			for _, s := range sections {
+				if s.Exists(opt.Name) {
+					stale = append(stale, StaleOption{
+						Section: s.Name(), Option: opt.Name, RemovedIn: opt.RemovedIn,
+						ReplacedBy: opt.ReplacedBy, Line: s.LineOf(opt.Name),
+					})
+				}
+			}
+		}
+	}
+	return stale
+}
+
+// writeCommentedDefaults writes c to w like Write, adding the options of the schema that are not set
+// as comments, see WithCommentedDefaults
+func writeCommentedDefaults(c *Configuration, w io.Writer, schema *Schema, source string) error {
+	global, sections, err := c.AllSections()
+	if err != nil {
+		return err
+	}
+	comment := c.opts.commentChar() + " "
+	bw := bufio.NewWriter(w)
+
+	// hasComment returns true if one of the sections already has the comment line, e.g. from an earlier save.
+	// Comments are stored as options, which are split at the delimiter like any other.
+	all := append([]*Section{global}, sections...)
+	hasComment := func(line string, in ...*Section) bool {
+		opt, _ := c.opts.parseOption(line)
+		for _, s := range in {
+			if s.Exists(opt) {
+				return true
+			}
+		}
+		return false
+	}
+	defaultLine := func(opt OptionSchema) string {
+		if opt.Default == "" {
+			return comment + opt.Name + strings.TrimRight(Delimiter, " ")
+		}
+		return comment + opt.Name + Delimiter + opt.Default
+	}
+
+	// sections excluded by their condition are written as they are
+	excluded := make(map[*Section]bool)
+	for _, s := range c.ExcludedSections() {
+		excluded[s] = true
+	}
+
+	written := make(map[string]bool)
+	for _, s := range append([]*Section{global}, c.withExcluded(sections)...) {
+		bw.WriteString(s.format(source))
+		ss := schema.sectionOf(s)
+		if ss == nil || excluded[s] {
+			continue
+		}
+		written[ss.Name] = true
+		for _, opt := range ss.Options {
+			if s.Exists(opt.Name) || hasComment(defaultLine(opt), s) {
+				continue
+			}
+			if opt.Description != "" && !hasComment(comment+opt.Description, s) {
+				bw.WriteString(comment + opt.Description + "\n")
+			}
+			bw.WriteString(defaultLine(opt) + "\n")
+		}
+	}
+
+	for _, ss := range schema.Sections {
+		header := comment + "[" + ss.Name + "]"
+		if written[ss.Name] || ss.Name == "" || hasComment(header, all...) {
+			continue
+		}
+		bw.WriteString(header + "\n")
+		if ss.Description != "" {
+			bw.WriteString(comment + ss.Description + "\n")
+		}
+		for _, opt := range ss.Options {
+			if opt.Description != "" {
+				bw.WriteString(comment + opt.Description + "\n")
+			}
+			bw.WriteString(defaultLine(opt) + "\n")
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,288 @@
+package configparser
+
+import (
+	"strings"
+	"testing"
+)
+
+var testSchema = &Schema{
+	Sections: []SectionSchema{
+		{
+			Name: "server",
+			Options: []OptionSchema{
+				{Name: "host", Default: "localhost"},
+				{Name: "port", Default: "8080"},
+				{Name: "tls_cert"},
+			},
+		},
+		{
+			Name: "log",
+			Options: []OptionSchema{
+				{Name: "level", Default: "info"},
+			},
+		},
+	},
+}
+
+func TestApplyDefaults(t *testing.T) {
+	conf, err := Read(strings.NewReader("[server]\nport = 80\n[server]\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ApplyDefaults(conf, testSchema)
+
+	exp := "[server]\nport" + Delimiter + "80\nhost" + Delimiter + "localhost\n" +
+		"[server]\nhost" + Delimiter + "localhost\nport" + Delimiter + "8080\n" +
+		"[log]\nlevel" + Delimiter + "info\n"
+	if conf.String() != exp {
+		t.Fatalf("config mismatch\nexp %q\ngot %q", exp, conf.String())
+	}
+
+	s, _ := conf.Section("server")
+	if s.IsDefaulted("port") || !s.IsDefaulted("host") || s.IsDefaulted("tls_cert") || s.Exists("tls_cert") {
+		t.Fatal("unexpected defaulted options")
+	}
+}
+
+func TestSchemaGlobalSection(t *testing.T) {
+	schema := &Schema{
+		Sections: []SectionSchema{
+			{
+				Name: "",
+				Options: []OptionSchema{
+					{Name: "prefix", HasDefault: true},
+					{Name: "port", Min: Float(1)},
+					{Name: "old", RemovedIn: "2.0"},
+				},
+			},
+		},
+	}
+	conf, err := Read(strings.NewReader("old = 1\n[server]\nport = 0\nold = 1\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(conf, schema); err != nil {
+		t.Fatalf("expected only the global section to be validated, got %v", err)
+	}
+	if stale := StaleOptions(conf, schema); len(stale) != 1 || stale[0].Line != 1 {
+		t.Fatalf("expected only the global option to be stale, got %v", stale)
+	}
+	ApplyDefaults(conf, schema)
+	exp := "old" + Delimiter + "1\nprefix" + strings.TrimRight(Delimiter, " ") + "\n[server]\nport" + Delimiter + "0\nold" + Delimiter + "1\n"
+	if conf.String() != exp {
+		t.Fatalf("config mismatch\nexp %q\ngot %q", exp, conf.String())
+	}
+
+	conf.GlobalSection().Add("port", "0")
+	if err := Validate(conf, schema); err == nil {
+		t.Fatal("expected the global section to be validated")
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	schema := &Schema{
+		Sections: []SectionSchema{
+			{
+				Name: "server",
+				Options: []OptionSchema{
+					{Name: "listen", Pattern: `[^:]*:[0-9]+`, PatternDescription: "host:port"},
+					{Name: "name", Pattern: `[a-z]+`},
+				},
+			},
+		},
+	}
+
+	conf, err := Read(strings.NewReader("[server]\nlisten = localhost:80\nname = web\n"), "", WithSchema(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(conf, schema); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Read(strings.NewReader("[server]\nlisten = localhost\nname = web1\n"), "test.ini", WithSchema(schema))
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a *ValidationError, got %v", err)
+	}
+	if len(verr.Violations) != 2 {
+		t.Fatalf("expected 2 violations, got %v", verr.Violations)
+	}
+	exp := `test.ini:2: [server] listen = "localhost": must be host:port; test.ini:3: [server] name = "web1": must match [a-z]+`
+	if err.Error() != exp {
+		t.Fatalf("expected error %q, got %q", exp, err.Error())
+	}
+
+	invalid := &Schema{Sections: []SectionSchema{{Name: "server", Options: []OptionSchema{{Name: "listen", Pattern: "("}}}}}
+	if err := Validate(conf, invalid); err == nil {
+		t.Fatal("expected error for invalid pattern")
+	}
+}
+
+func TestValidateRange(t *testing.T) {
+	schema := &Schema{
+		Sections: []SectionSchema{
+			{
+				Name: "server",
+				Options: []OptionSchema{
+					{Name: "port", Type: "int", Min: Float(1), Max: Float(65535)},
+					{Name: "workers", Type: "int", Min: Float(2), Step: Float(2)},
+					{Name: "ratio", Type: "float", Max: Float(1), Step: Float(0.25)},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		in  string
+		exp string
+	}{
+		{"port = 80\nworkers = 4\nratio = 0.75", ""},
+		{"port = 08\nworkers = 010", ""},
+		{"port = 0x50", `line 2: [server] port = "0x50": must be an integer`},
+		{"port = 0", `line 2: [server] port = "0": must be at least 1`},
+		{"port = 70000", `line 2: [server] port = "70000": must be at most 65535`},
+		{"port = 80.5", `line 2: [server] port = "80.5": must be an integer`},
+		{"workers = 5", `line 2: [server] workers = "5": must be 2 plus a multiple of 2`},
+		{"ratio = 0.3", `line 2: [server] ratio = "0.3": must be a multiple of 0.25`},
+		{"ratio = 1.5", `line 2: [server] ratio = "1.5": must be at most 1`},
+		{"ratio = NaN", `line 2: [server] ratio = "NaN": must be a number`},
+	}
+	for _, test := range tests {
+		conf, err := Read(strings.NewReader("[server]\n"+test.in+"\n"), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Validate(conf, schema)
+		if test.exp == "" {
+			if err != nil {
+				t.Fatalf("%q: unexpected error %s", test.in, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.exp {
+			t.Fatalf("%q: expected error %q, got %v", test.in, test.exp, err)
+		}
+	}
+}
+
+func TestValidateGroups(t *testing.T) {
+	schema := &Schema{
+		Sections: []SectionSchema{
+			{
+				Name:         "server",
+				ExactlyOneOf: [][]string{{"unix_socket", "tcp_addr"}},
+				AtMostOneOf:  [][]string{{"debug", "quiet"}},
+				Requires:     map[string][]string{"tls_cert": {"tls_key"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		in  string
+		exp string
+	}{
+		{"tcp_addr = :80\ntls_cert = a.pem\ntls_key = a.key\nquiet", ""},
+		{"debug", `[server]: exactly one of unix_socket, tcp_addr must be set, found none`},
+		{"unix_socket = /s\ntcp_addr = :80", `[server]: exactly one of unix_socket, tcp_addr must be set, found unix_socket, tcp_addr`},
+		{"unix_socket = /s\ndebug\nquiet", `[server]: at most one of debug, quiet may be set, found debug, quiet`},
+		{"unix_socket = /s\ntls_cert = a.pem", `line 3: [server] tls_cert = "a.pem": requires tls_key to be set`},
+	}
+	for _, test := range tests {
+		conf, err := Read(strings.NewReader("[server]\n"+test.in+"\n"), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Validate(conf, schema)
+		if test.exp == "" {
+			if err != nil {
+				t.Fatalf("%q: unexpected error %s", test.in, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.exp {
+			t.Fatalf("%q: expected error %q, got %v", test.in, test.exp, err)
+		}
+	}
+}
+
+func TestValidateChecks(t *testing.T) {
+	routes := func(conf *Configuration) []Violation {
+		sections, _ := conf.Find("^route-")
+		var violations []Violation
+		for _, s := range sections {
+			dest := s.ValueOf("destination")
+			if _, err := conf.Section("destination-" + dest); err != nil {
+				violations = append(violations, Violation{
+					Section: s.Name(), Option: "destination", Value: dest,
+					Message: "no such destination", Line: s.LineOf("destination"),
+				})
+			}
+		}
+		return violations
+	}
+	schema := &Schema{
+		Sections: []SectionSchema{
+			{Name: "route-a", Options: []OptionSchema{{Name: "destination", Pattern: "[a-z]+"}}},
+		},
+		Checks: []Check{routes},
+	}
+
+	in := `[destination-db]
+[route-a]
+destination = db
+[route-b]
+destination = cache
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `line 5: [route-b] destination = "cache": no such destination`
+	if err := Validate(conf, schema); err == nil || err.Error() != exp {
+		t.Fatalf("expected error %q, got %v", exp, err)
+	}
+
+	s, _ := conf.Section("route-a")
+	s.SetValueFor("destination", "DB")
+	err = Validate(conf, schema)
+	if verr, ok := err.(*ValidationError); !ok || len(verr.Violations) != 3 {
+		t.Fatalf("expected per-option and check violations to be reported together, got %v", err)
+	}
+}
+
+func TestStaleOptions(t *testing.T) {
+	schema := &Schema{
+		Sections: []SectionSchema{
+			{
+				Name: "server",
+				Options: []OptionSchema{
+					{Name: "addr", RemovedIn: "2.0", ReplacedBy: "listen"},
+					{Name: "listen"},
+					{Name: "workers", RemovedIn: "3.1"},
+				},
+			},
+			{
+				Name:    "log",
+				Options: []OptionSchema{{Name: "file", RemovedIn: "2.0"}},
+			},
+		},
+	}
+
+	conf, err := Read(strings.NewReader("[server]\nworkers = 4\naddr = :80\n[server]\naddr = :81\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, so := range StaleOptions(conf, schema) {
+		got = append(got, so.String())
+	}
+	exp := []string{
+		"line 3: [server] addr was removed in 2.0, use listen instead",
+		"line 5: [server] addr was removed in 2.0, use listen instead",
+		"line 2: [server] workers was removed in 3.1",
+	}
+	if strings.Join(got, "\n") != strings.Join(exp, "\n") {
+		t.Fatalf("expected:\n%s\ngot:\n%s", strings.Join(exp, "\n"), strings.Join(got, "\n"))
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"sort"
+	"strings"
+)
+
+// LexicalLess orders names lexicographically, byte by byte.
+func LexicalLess(a, b string) bool {
+	return a < b
+}
+
+// NaturalLess orders names naturally, comparing runs of digits by their numeric value,
+// so that "host2" sorts before "host10".
+func NaturalLess(a, b string) bool {
+	// names that only differ in leading zeros are ordered by the first difference, fewer zeros first
+	zeros := 0
+	for a != "" && b != "" {
+		var chunkA, chunkB string
+		chunkA, a = nextChunk(a)
+		chunkB, b = nextChunk(b)
+		if chunkA == chunkB {
+			continue
+		}
+		if !isDigit(chunkA[0]) || !isDigit(chunkB[0]) {
+			return chunkA < chunkB
+		}
+		numA, numB := strings.TrimLeft(chunkA, "0"), strings.TrimLeft(chunkB, "0")
+		if len(numA) != len(numB) {
+			return len(numA) < len(numB)
+		}
+		if numA != numB {
+			return numA < numB
+		}
+		if zeros == 0 {
+			zeros = len(chunkA) - len(chunkB)
+		}
+	}
+	if a == "" && b == "" {
+		return zeros < 0
+	}
+	return a == ""
+}
+
+// SortSections reorders the non-global sections by name according to less, e.g. LexicalLess or NaturalLess.
+// Sections with the same name keep their relative order. This changes the order in which sections are written.
+func (c *Configuration) SortSections(less func(a, b string) bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	sort.SliceStable(c.orderedSections, func(i, j int) bool {
+		return less(c.orderedSections[i], c.orderedSections[j])
+	})
+}
+
+// SortOptions reorders the options of the section by name according to less, e.g. LexicalLess or NaturalLess.
+// Note that comments and empty lines are tracked as options and are sorted along with them.
+func (s *Section) SortOptions(less func(a, b string) bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sort.SliceStable(s.orderedOptions, func(i, j int) bool {
+		return less(s.orderedOptions[i], s.orderedOptions[j])
+	})
+}
+
+// OrderedLess returns a less function that orders the given names first, in the given order, and all other
+// names after them, ordered by then, or in their current order if then is nil. For example,
+// SortSections(OrderedLess([]string{"main"}, LexicalLess)) moves [main] to the front and sorts the other sections.
+func OrderedLess(first []string, then func(a, b string) bool) func(a, b string) bool {
+	rank := make(map[string]int, len(first))
+	for i, name := range first {
+		if _, ok := rank[name]; !ok {
+			rank[name] = i
+		}
+	}
+	return func(a, b string) bool {
+		rankA, okA := rank[a]
+		rankB, okB := rank[b]
+		switch {
+		case okA && okB:
+			return rankA < rankB
+		case okA || okB:
+			return okA
+		case then != nil:
+			return then(a, b)
+		}
+		return false
+	}
+}
+
+// sorted returns an independent copy of c with its sections and options reordered, see WithOrder.
+// A nil less function keeps the current order.
+func (c *Configuration) sorted(sections, options func(a, b string) bool) *Configuration {
+	c.mutex.RLock()
+	sorted := newConfiguration(c.filePath, c.opts)
+	sorted.global = c.global.copy()
+	copies := make(map[*Section]*Section)
+	for _, fqn := range c.orderedSections {
+		for e := c.sections[fqn].Front(); e != nil; e = e.Next() {
+			copies[e.Value.(*Section)] = e.Value.(*Section).copy()
+			sorted.insertSection(copies[e.Value.(*Section)])
+		}
+	}
+	for _, e := range c.excluded {
+		sorted.excluded = append(sorted.excluded, excludedSection{after: copies[e.after], section: e.section.copy()})
+	}
+	c.mutex.RUnlock()
+
+	if sections != nil {
+		sorted.SortSections(sections)
+	}
+	if options != nil {
+		global, all, _ := sorted.AllSections()
+		for _, s := range append([]*Section{global}, all...) {
+			s.SortOptions(options)
+		}
+	}
+	return sorted
+}
+
+// inFileOrder sorts the sections in the order they were read: by file, in the order the files first appear in
+// sections, and by line within each file. Sections that weren't read from a source keep their relative order
+// after all others.
+func inFileOrder(sections []*Section) {
+	files := make(map[string]int)
+	lines := make(map[*Section]int, len(sections))
+	for _, s := range sections {
+		s.mutex.RLock()
+		lines[s] = s.firstLine
+		if _, ok := files[s.file]; !ok && s.firstLine > 0 {
+			files[s.file] = len(files)
+		}
+		s.mutex.RUnlock()
+	}
+	sort.SliceStable(sections, func(i, j int) bool {
+		a, b := sections[i], sections[j]
+		switch {
+		case lines[a] == 0 || lines[b] == 0:
+			return lines[b] == 0 && lines[a] != 0
+		case files[a.file] != files[b.file]:
+			return files[a.file] < files[b.file]
+		}
+		return lines[a] < lines[b]
+	})
+}
+
+// nextChunk splits s into its leading run of either digits or non-digits, and the rest
+func nextChunk(s string) (chunk, rest string) {
+	digits := isDigit(s[0])
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digits {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
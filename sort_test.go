@@ -0,0 +1,86 @@
+package configparser
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNaturalLess(t *testing.T) {
+	names := []string{"host10", "host2", "db", "host1", "host02", "host", "host2b", "host2a", "a10b2", "a10b10", "a9"}
+	sort.SliceStable(names, func(i, j int) bool { return NaturalLess(names[i], names[j]) })
+
+	exp := []string{"a9", "a10b2", "a10b10", "db", "host", "host1", "host2", "host02", "host2a", "host2b", "host10"}
+	if !reflect.DeepEqual(names, exp) {
+		t.Fatalf("unexpected order\nexp %q\ngot %q", exp, names)
+	}
+}
+
+func TestSortSections(t *testing.T) {
+	in := `[host10]
+a = 1
+[host2]
+b = 2
+[host10]
+c = 3
+[host1]
+z10 = 1
+z9 = 2
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf.SortSections(NaturalLess)
+	_, sections, _ := conf.AllSections()
+	var got []string
+	for _, s := range sections {
+		got = append(got, s.Name()+":"+strings.Join(s.OptionNames(), ","))
+	}
+	exp := []string{"host1:z10,z9", "host2:b", "host10:a", "host10:c"}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("unexpected order\nexp %q\ngot %q", exp, got)
+	}
+
+	conf.SortSections(LexicalLess)
+	s, _ := conf.Section("host1")
+	s.SortOptions(NaturalLess)
+	if got := conf.String(); !strings.HasPrefix(got, "[host1]\nz9"+Delimiter+"2\nz10"+Delimiter+"1\n[host10]") {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestOrderedLess(t *testing.T) {
+	names := []string{"zeta", "main", "alpha", "logging", "beta"}
+	sort.SliceStable(names, func(i, j int) bool { return OrderedLess([]string{"main", "logging"}, LexicalLess)(names[i], names[j]) })
+	if exp := []string{"main", "logging", "alpha", "beta", "zeta"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("unexpected order\nexp %q\ngot %q", exp, names)
+	}
+
+	sort.SliceStable(names, func(i, j int) bool { return OrderedLess([]string{"beta"}, nil)(names[i], names[j]) })
+	if exp := []string{"beta", "main", "logging", "alpha", "zeta"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("unexpected order\nexp %q\ngot %q", exp, names)
+	}
+}
+
+func TestSaveWithOrder(t *testing.T) {
+	fs := NewMemFS()
+	conf, err := Read(strings.NewReader("top = 1\n[zeta]\nb = 2\na = 1\n[main]\ny = 1\nx = 2\n[alpha]\n"), "app.ini", WithSaveTarget(fs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := conf.String()
+	if err := conf.Save(WithOrder(OrderedLess([]string{"main"}, LexicalLess), LexicalLess)); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := fs.ReadFile("app.ini")
+	exp := strings.NewReplacer(" = ", Delimiter).Replace("top = 1\n[main]\nx = 2\ny = 1\n[alpha]\n[zeta]\na = 1\nb = 2\n")
+	if string(data) != exp {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, data)
+	}
+	if conf.String() != before {
+		t.Fatal("expected the configuration to keep its order")
+	}
+}
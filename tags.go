@@ -0,0 +1,54 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"strings"
+)
+
+// TagsOption is the name of the option holding the comma separated tags of a section, e.g. "tags = web, frontend"
+const TagsOption = "tags"
+
+// Tags returns the tags of the section, as listed in its TagsOption.
+func (s *Section) Tags() []string {
+	var tags []string
+	for _, tag := range strings.Split(s.ValueOfWithoutComments(TagsOption), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// HasTag returns true if the section is tagged with tag.
+func (s *Section) HasTag(tag string) bool {
+	for _, t := range s.Tags() {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTag tags the section with tag, adding it to its TagsOption. Adding an existing tag is a no-op.
+func (s *Section) AddTag(tag string) {
+	if s.HasTag(tag) {
+		return
+	}
+	s.Add(TagsOption, strings.Join(append(s.Tags(), tag), ", "))
+}
+
+// SectionsWithTag returns a slice of all non-global sections tagged with tag, in the order they appear.
+func (c *Configuration) SectionsWithTag(tag string) []*Section {
+	_, all, _ := c.AllSections()
+
+	var sections []*Section
+	for _, s := range all {
+		if s.HasTag(tag) {
+			sections = append(sections, s)
+		}
+	}
+	return sections
+}
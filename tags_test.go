@@ -0,0 +1,48 @@
+package configparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSectionsWithTag(t *testing.T) {
+	in := `[web1]
+tags = web, frontend
+[db1]
+tags = db # primary
+[web2]
+tags = frontend,web,,
+[cache]
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(sections []*Section) []string {
+		var out []string
+		for _, s := range sections {
+			out = append(out, s.Name())
+		}
+		return out
+	}
+
+	if got := names(conf.SectionsWithTag("web")); !reflect.DeepEqual(got, []string{"web1", "web2"}) {
+		t.Fatalf("unexpected sections tagged web: %q", got)
+	}
+	if got := names(conf.SectionsWithTag("db")); !reflect.DeepEqual(got, []string{"db1"}) {
+		t.Fatalf("unexpected sections tagged db: %q", got)
+	}
+
+	cache, _ := conf.Section("cache")
+	cache.AddTag("web")
+	cache.AddTag("cache")
+	cache.AddTag("web")
+	if got := cache.ValueOf(TagsOption); got != "web, cache" {
+		t.Fatalf("unexpected tags option %q", got)
+	}
+	if got := names(conf.SectionsWithTag("web")); !reflect.DeepEqual(got, []string{"web1", "web2", "cache"}) {
+		t.Fatalf("unexpected sections tagged web: %q", got)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TemplatePrefix starts the names of template sections, e.g. [template:worker], see InstantiateTemplate.
+const TemplatePrefix = "template" + ProfileSeparator
+
+// InstantiateTemplate adds a section named name, holding the options and comments of the template section
+// [template:<template>], with ${var} in values replaced by vars["var"], for configurations with many
+// near-identical sections. The variable ${name} is the name of the new section unless set in vars,
+// and $$ is a literal $. The new section is returned.
+// An error is returned if the template doesn't exist, a value refers to an undefined variable or
+// a section with the name already exists.
+func (c *Configuration) InstantiateTemplate(template, name string, vars map[string]string) (*Section, error) {
+	tmpl, err := c.Section(TemplatePrefix + template)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to find template %s", template)
+	}
+	if _, err := c.Section(name); err == nil {
+		return nil, fmt.Errorf("section %s already exists", name)
+	}
+	if _, ok := vars["name"]; !ok {
+		all := map[string]string{"name": name}
+		for k, v := range vars {
+			all[k] = v
+		}
+		vars = all
+	}
+
+	s := tmpl.copy()
+	s.fqn = c.opts.sectionName(name)
+	s.header = ""
+	s.rawLines, s.firstLine = nil, 0
+	s.lines = nil
+	for _, opt := range s.orderedOptions {
+		if opt == "" || s.opts.isComment(opt) {
+			continue
+		}
+		value, err := expandTemplate(s.options[opt], vars)
+		if err != nil {
+			return nil, fmt.Errorf("template %s, option %s: %s", template, opt, err)
+		}
+		s.options[opt] = value
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.insertSection(s)
+	return s, nil
+}
+
+// expandTemplate replaces ${var} in value with vars["var"], and $$ with $
+func expandTemplate(value string, vars map[string]string) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(value, '$')
+		if i == -1 || i == len(value)-1 {
+			b.WriteString(value)
+			return b.String(), nil
+		}
+		b.WriteString(value[:i])
+		switch value[i+1] {
+		case '$':
+			b.WriteByte('$')
+			value = value[i+2:]
+		case '{':
+			end := strings.IndexByte(value[i:], '}')
+			if end == -1 {
+				return "", fmt.Errorf("unterminated variable in %q", value[i:])
+			}
+			v, ok := vars[value[i+2:i+end]]
+			if !ok {
+				return "", fmt.Errorf("undefined variable %q", value[i+2:i+end])
+			}
+			b.WriteString(v)
+			value = value[i+end+1:]
+		default:
+			b.WriteByte('$')
+			value = value[i+1:]
+		}
+	}
+}
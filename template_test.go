@@ -0,0 +1,80 @@
+package configparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInstantiateTemplate(t *testing.T) {
+	in := `[template:worker]
+# a worker
+queue = ${name}-queue
+threads = ${threads}
+cost = $$5
+[other]
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := conf.InstantiateTemplate("worker", "worker-3", map[string]string{"threads": "4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name() != "worker-3" || len(s.RawLines()) != 0 || s.LineOf("queue") != 0 {
+		t.Fatalf("unexpected section %q", s.Name())
+	}
+	exp := strings.NewReplacer(" = ", Delimiter).Replace(`[worker-3]
+# a worker
+queue = worker-3-queue
+threads = 4
+cost = $5
+`)
+	if s.String() != exp {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, s.String())
+	}
+	if v, _ := conf.StringValue("template:worker", "threads"); v != "${threads}" {
+		t.Fatalf("expected the template to be left alone, got %q", v)
+	}
+	if sections, _ := conf.Sections(""); sections[len(sections)-1] != s {
+		t.Fatal("expected the section to be added at the end")
+	}
+
+	tests := []struct {
+		template, name string
+		vars           map[string]string
+	}{
+		{"missing", "worker-4", nil},
+		{"worker", "worker-3", map[string]string{"threads": "4"}},
+		{"worker", "worker-4", nil},
+	}
+	for _, test := range tests {
+		if _, err := conf.InstantiateTemplate(test.template, test.name, test.vars); err == nil {
+			t.Fatalf("%s %s: expected error", test.template, test.name)
+		}
+	}
+	if _, err := conf.Section("worker-4"); err == nil {
+		t.Fatal("expected no section to be added on error")
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	vars := map[string]string{"a": "1", "b": "2"}
+	tests := []struct {
+		in, exp string
+		err     bool
+	}{
+		{"", "", false},
+		{"${a}${b}", "12", false},
+		{"x $a $", "x $a $", false},
+		{"$${a}", "${a}", false},
+		{"${a", "", true},
+		{"${c}", "", true},
+	}
+	for _, test := range tests {
+		got, err := expandTemplate(test.in, vars)
+		if (err != nil) != test.err || got != test.exp {
+			t.Fatalf("%q: expected %q, error %t, got %q, %v", test.in, test.exp, test.err, got, err)
+		}
+	}
+}
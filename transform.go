@@ -0,0 +1,74 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// metaTransform is the option metadata key marking options that skip the transformers, see SkipTransformers
+const metaTransform = "transform"
+
+// A Transformer processes the value of an option when it is accessed, e.g. to expand environment
+// variables, decrypt a secret or render a template. It gets the section and option names for context.
+type Transformer func(section, option, value string) (string, error)
+
+// WithTransformers adds transformers that are applied, in order, to values returned by ValueOf and
+// ValueOfWithoutComments, after interpolation. Values are stored and saved as they were read.
+// Individual options can opt out with SkipTransformers.
+func WithTransformers(transformers ...Transformer) Option {
+	return func(o *options) {
+		o.transformers = append(o.transformers, transformers...)
+	}
+}
+
+// TrimTransformer removes leading and trailing whitespace from values.
+func TrimTransformer(section, option, value string) (string, error) {
+	return strings.TrimSpace(value), nil
+}
+
+// EnvTransformer replaces $var and ${var} in values with the value of the environment variable var.
+func EnvTransformer(section, option, value string) (string, error) {
+	return os.ExpandEnv(value), nil
+}
+
+// SkipTransformers makes the option bypass the transformers of WithTransformers, e.g. for values that
+// contain a literal $.
+func (s *Section) SkipTransformers(option string) {
+	s.SetOptionMeta(option, metaTransform, "skip")
+}
+
+// TransformedValueOf returns the value of the option like ValueOf, but returns the error of a failing
+// Transformer instead of the untransformed value.
+func (s *Section) TransformedValueOf(option string) (string, error) {
+	return s.transform(option, s.maybeInterpolate(option, s.RawValueOf(option)))
+}
+
+// maybeTransform applies the transformers to value, returning it unchanged if one fails
+func (s *Section) maybeTransform(option, value string) string {
+	v, err := s.transform(option, value)
+	if err != nil {
+		s.opts.log().Warn("failed to transform value", "section", s.Name(), "option", option, "error", err)
+		return value
+	}
+	return v
+}
+
+// transform applies the transformers to value, the value of option
+func (s *Section) transform(option, value string) (string, error) {
+	if s.opts == nil || len(s.opts.transformers) == 0 || s.OptionMeta(option, metaTransform) == "skip" || s.IsConstant(option) {
+		return value, nil
+	}
+	for _, t := range s.opts.transformers {
+		var err error
+		value, err = t(s.Name(), option, value)
+		if err != nil {
+			return "", fmt.Errorf("section %q, option %q: %s", s.Name(), option, err)
+		}
+	}
+	return value, nil
+}
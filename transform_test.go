@@ -0,0 +1,48 @@
+package configparser
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTransformers(t *testing.T) {
+	os.Setenv("CONFIGPARSER_TEST_HOME", "/home/test")
+	defer os.Unsetenv("CONFIGPARSER_TEST_HOME")
+
+	upper := func(section, option, value string) (string, error) {
+		if value == "fail" {
+			return "", errors.New("cannot transform")
+		}
+		return section + ":" + strings.ToUpper(value), nil
+	}
+	in := `[foo]
+dir = $CONFIGPARSER_TEST_HOME/data
+price = $5
+bad = fail
+`
+	conf, err := Read(strings.NewReader(in), "", WithTransformers(EnvTransformer, upper))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("foo")
+	if v := s.ValueOf("dir"); v != "foo:/HOME/TEST/DATA" {
+		t.Fatalf("expected transformers to be applied in order, got %q", v)
+	}
+	if v := s.RawValueOf("dir"); v != "$CONFIGPARSER_TEST_HOME/data" {
+		t.Fatalf("expected the raw value to be kept, got %q", v)
+	}
+
+	s.SkipTransformers("price")
+	if v := s.ValueOf("price"); v != "$5" {
+		t.Fatalf("expected the option to opt out, got %q", v)
+	}
+
+	if v := s.ValueOf("bad"); v != "fail" {
+		t.Fatalf("expected the untransformed value on error, got %q", v)
+	}
+	if _, err := s.TransformedValueOf("bad"); err == nil || !strings.Contains(err.Error(), "cannot transform") {
+		t.Fatalf("expected the transformer's error, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ratePeriods are the period names accepted by RateOf
+var ratePeriods = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+}
+
+// byteUnits are the units accepted by BytesOf, matched case-insensitively
+var byteUnits = map[string]float64{
+	"": 1, "b": 1,
+	"kb": 1e3, "mb": 1e6, "gb": 1e9, "tb": 1e12,
+	"k": 1 << 10, "m": 1 << 20, "g": 1 << 30, "t": 1 << 40,
+	"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30, "tib": 1 << 40,
+}
+
+// RateOf returns the value of the option, a rate such as "500/min", "10/s" or "3/5m", converted to events
+// per second. The period is s, m, h or d, spelled out or not (e.g. "min" or "minutes"), or a duration
+// accepted by time.ParseDuration. A number without a period is a rate per second.
+// Comments are removed first, see ValueOfWithoutComments.
+func (s *Section) RateOf(option string) (float64, error) {
+	value := s.ValueOfWithoutComments(option)
+	count, period := value, "s"
+	if i := strings.IndexByte(value, '/'); i != -1 {
+		count, period = strings.TrimSpace(value[:i]), strings.TrimSpace(value[i+1:])
+	}
+
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil {
+		return 0, fmt.Errorf("option %s: invalid rate %q", option, value)
+	}
+	d, ok := ratePeriods[period]
+	if !ok {
+		if d, err = time.ParseDuration(period); err != nil || d <= 0 {
+			return 0, fmt.Errorf("option %s: invalid period in rate %q", option, value)
+		}
+	}
+	return n / d.Seconds(), nil
+}
+
+// PercentOf returns the value of the option, a percentage such as "50%" or a fraction such as "0.5",
+// as a fraction, so both return 0.5. Comments are removed first, see ValueOfWithoutComments.
+func (s *Section) PercentOf(option string) (float64, error) {
+	value := s.ValueOfWithoutComments(option)
+	number, div := value, 1.0
+	if strings.HasSuffix(value, "%") {
+		number, div = strings.TrimSpace(strings.TrimSuffix(value, "%")), 100
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("option %s: invalid percentage %q", option, value)
+	}
+	return n / div, nil
+}
+
+// BytesOf returns the value of the option, a size such as "512", "10MB" or "1.5 GiB", in bytes.
+// kB, MB, GB and TB are powers of 1000, while KiB, MiB, GiB and TiB, as well as K, M, G and T
+// as used by e.g. nginx and Java, are powers of 1024. Units are case-insensitive.
+// Comments are removed first, see ValueOfWithoutComments.
+func (s *Section) BytesOf(option string) (int64, error) {
+	value := s.ValueOfWithoutComments(option)
+	i := strings.IndexFunc(value, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i == -1 {
+		i = len(value)
+	}
+	n, err := strconv.ParseFloat(value[:i], 64)
+	unit, ok := byteUnits[strings.ToLower(strings.TrimSpace(value[i:]))]
+	// math.MaxInt64 is rounded up to 2^63 as a float, which doesn't fit
+	if err != nil || !ok || n*unit >= math.MaxInt64 {
+		return 0, fmt.Errorf("option %s: invalid size %q", option, value)
+	}
+	return int64(math.Round(n * unit)), nil
+}
@@ -0,0 +1,64 @@
+package configparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnits(t *testing.T) {
+	in := `[limits]
+rate_min = 600/min
+rate_s = 10 / s
+rate_5m = 300/5m # per five minutes
+rate_plain = 2.5
+rate_bad = 1/fortnight
+rate_nan = x/s
+pct = 50%
+fraction = 0.25
+pct_bad = 50 percent
+size = 512
+size_mb = 10MB
+size_gib = 1.5 GiB
+size_k = 64k
+size_bad = 1XB
+size_neg = -1
+size_max = 8388607 TiB
+size_2p63 = 8388608 TiB
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := conf.Section("limits")
+
+	for opt, exp := range map[string]float64{"rate_min": 10, "rate_s": 10, "rate_5m": 1, "rate_plain": 2.5} {
+		if v, err := s.RateOf(opt); err != nil || v != exp {
+			t.Fatalf("%s: expected %v, got %v, %v", opt, exp, v, err)
+		}
+	}
+	for _, opt := range []string{"rate_bad", "rate_nan"} {
+		if _, err := s.RateOf(opt); err == nil {
+			t.Fatalf("%s: expected error", opt)
+		}
+	}
+
+	for opt, exp := range map[string]float64{"pct": 0.5, "fraction": 0.25} {
+		if v, err := s.PercentOf(opt); err != nil || v != exp {
+			t.Fatalf("%s: expected %v, got %v, %v", opt, exp, v, err)
+		}
+	}
+	if _, err := s.PercentOf("pct_bad"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	for opt, exp := range map[string]int64{"size": 512, "size_mb": 10000000, "size_gib": 3 << 29, "size_k": 65536, "size_max": 8388607 << 40} {
+		if v, err := s.BytesOf(opt); err != nil || v != exp {
+			t.Fatalf("%s: expected %v, got %v, %v", opt, exp, v, err)
+		}
+	}
+	for _, opt := range []string{"size_bad", "size_neg", "size_2p63", "missing"} {
+		if _, err := s.BytesOf(opt); err == nil {
+			t.Fatalf("%s: expected error", opt)
+		}
+	}
+}
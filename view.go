@@ -0,0 +1,111 @@
+// Copyright (c) 2013 - Alex Yu <alex@alexyu.se>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configparser
+
+import (
+	"errors"
+)
+
+// View is a read-only view of the non-global sections of a Configuration that match a predicate.
+// It reflects later changes to the Configuration, but the sections it returns are copies,
+// so it can't be used to change the Configuration. Section, Sections and Find copy every section they return,
+// only after it passed the predicate, while StringValue reads the value without copying.
+type View struct {
+	config    *Configuration
+	predicate func(*Section) bool
+}
+
+// View returns a read-only View restricted to the non-global sections for which predicate returns true,
+// e.g. for handing a subsystem only the part of the configuration it is allowed to see.
+// The global section is never visible, not even under the name set with WithGlobalName,
+// and is never passed to predicate.
+func (c *Configuration) View(predicate func(*Section) bool) *View {
+	return &View{
+		config:    c,
+		predicate: predicate,
+	}
+}
+
+// Section returns a copy of the first visible section matching the fully qualified section name.
+func (v *View) Section(fqn string) (*Section, error) {
+	s, err := v.section(fqn)
+	if err != nil {
+		return nil, err
+	}
+	return viewCopy(s), nil
+}
+
+// Sections returns copies of the visible sections matching the fully qualified section name,
+// or of all visible sections if fqn is empty.
+func (v *View) Sections(fqn string) ([]*Section, error) {
+	sections, err := v.config.Sections(fqn)
+	if err != nil {
+		return nil, err
+	}
+	sections = v.filter(sections)
+	if fqn != "" && len(sections) == 0 {
+		return nil, errors.New("Unable to find " + fqn)
+	}
+	return copySections(sections), nil
+}
+
+// Find returns copies of the visible sections whose name matches the regexp.
+func (v *View) Find(regex string) ([]*Section, error) {
+	sections, err := v.config.Find(regex)
+	if err != nil {
+		return nil, err
+	}
+	return copySections(v.filter(sections)), nil
+}
+
+// StringValue returns the string value for the specified visible section and option.
+func (v *View) StringValue(section, option string) (string, error) {
+	s, err := v.section(section)
+	if err != nil {
+		return "", err
+	}
+	return s.ValueOf(option), nil
+}
+
+// section returns the first visible section matching fqn, without copying it
+func (v *View) section(fqn string) (*Section, error) {
+	sections, err := v.config.Sections(fqn)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sections {
+		if !s.isGlobal && v.predicate(s) {
+			return s, nil
+		}
+	}
+	return nil, errors.New("Unable to find " + fqn)
+}
+
+// filter returns the non-global sections matching the predicate
+func (v *View) filter(sections []*Section) []*Section {
+	var visible []*Section
+	for _, s := range sections {
+		if !s.isGlobal && v.predicate(s) {
+			visible = append(visible, s)
+		}
+	}
+	return visible
+}
+
+// copySections returns copies of sections for handing out through a View
+func copySections(sections []*Section) []*Section {
+	var copies []*Section
+	for _, s := range sections {
+		copies = append(copies, viewCopy(s))
+	}
+	return copies
+}
+
+// viewCopy returns a copy of s that still falls back to the defaults of s
+func viewCopy(s *Section) *Section {
+	c := s.copy()
+	c.defaults = s.defaults
+	return c
+}
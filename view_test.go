@@ -0,0 +1,81 @@
+package configparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestView(t *testing.T) {
+	in := `[db.primary]
+password = secret
+[web.frontend]
+port = 80
+[web.backend]
+port = 8080
+`
+	conf, err := Read(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := conf.View(func(s *Section) bool {
+		return strings.HasPrefix(s.Name(), "web.")
+	})
+
+	if _, err := view.Section("db.primary"); err == nil {
+		t.Fatal("expected hidden section not to be found")
+	}
+	if _, err := view.StringValue("db.primary", "password"); err == nil {
+		t.Fatal("expected hidden section not to be found")
+	}
+	if v, err := view.StringValue("web.backend", "port"); err != nil || v != "8080" {
+		t.Fatalf("unexpected value %q, %v", v, err)
+	}
+	if all, _ := view.Sections(""); len(all) != 2 {
+		t.Fatalf("expected 2 visible sections, got %d", len(all))
+	}
+	if found, _ := view.Find("primary|frontend"); len(found) != 1 || found[0].Name() != "web.frontend" {
+		t.Fatalf("unexpected sections found %v", found)
+	}
+
+	s, _ := view.Section("web.frontend")
+	s.SetValueFor("port", "81")
+	if v, _ := conf.StringValue("web.frontend", "port"); v != "80" {
+		t.Fatal("changes through a view must not affect the configuration")
+	}
+
+	conf.NewSection("web.new")
+	if _, err := view.Section("web.new"); err != nil {
+		t.Fatal("expected view to reflect new sections")
+	}
+}
+
+func TestViewGlobalSection(t *testing.T) {
+	in := "name = app\n[web]\nport = 80\n"
+	conf, err := Read(strings.NewReader(strings.Replace(in, " = ", Delimiter, -1)), "", WithGlobalName("DEFAULT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen []string
+	view := conf.View(func(s *Section) bool {
+		seen = append(seen, s.Name())
+		return true
+	})
+
+	if _, err := view.Section("DEFAULT"); err == nil {
+		t.Fatal("expected the global section not to be visible")
+	}
+	if _, err := view.StringValue("DEFAULT", "name"); err == nil {
+		t.Fatal("expected the global section not to be visible")
+	}
+	if _, err := view.Sections("DEFAULT"); err == nil {
+		t.Fatal("expected the global section not to be visible")
+	}
+	if all, _ := view.Sections(""); len(all) != 1 || all[0].Name() != "web" {
+		t.Fatalf("unexpected visible sections %v", all)
+	}
+	for _, name := range seen {
+		if name == "DEFAULT" {
+			t.Fatal("the global section must not be passed to the predicate")
+		}
+	}
+}